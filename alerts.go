@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// scrapeAlerter warns the maintainers in the debug channel when fetching a plan fails or today's
+// plan is empty on a weekday, at most once per ScrapeAlertInterval
+type scrapeAlerter struct {
+	sync.Mutex
+	// send posts the alert, it is nil until the bot is connected
+	send       func(msg string)
+	lastSent   time.Time
+	suppressed int
+}
+
+var SCRAPE_ALERTS scrapeAlerter
+
+// check raises an alert if the result of fetching the plan of the canteen for the day offset looks broken
+func (a *scrapeAlerter) check(canteenID string, offset int, dishes []dish, err error) {
+	var msg string
+	switch {
+	case err != nil:
+		msg = tr("alert.error", canteenID, offset, err)
+	case offset == 0 && len(dishes) == 0 && !isWeekend(time.Now()):
+		msg = tr("alert.empty", canteenID)
+	default:
+		return
+	}
+
+	a.Lock()
+	if a.send == nil || CONFIG.ScrapeAlertInterval.Duration <= 0 {
+		a.Unlock()
+		return
+	}
+	if time.Since(a.lastSent) < CONFIG.ScrapeAlertInterval.Duration {
+		a.suppressed++
+		a.Unlock()
+		return
+	}
+	if a.suppressed > 0 {
+		msg += " " + tr("alert.suppressed", a.suppressed)
+	}
+	a.lastSent, a.suppressed = time.Now(), 0
+	send := a.send
+	a.Unlock()
+
+	send(msg)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// categoryFilter selects the dishes of the categories whose names contain any of the terms, for
+// the keywords users ask for them with
+type categoryFilter struct {
+	keywords []string
+	terms    []string
+}
+
+// CATEGORY_FILTERS map the keywords of REG_EXP_CATEGORY to the category names used by the canteens
+var CATEGORY_FILTERS = []categoryFilter{
+	{[]string{"beilage", "beilagen", "side", "sides"}, []string{"beilage", "side"}},
+	{[]string{"hauptgericht", "hauptgerichte", "main", "mains"}, []string{"hauptgericht", "hauptspeise", "main"}},
+	{[]string{"dessert", "desserts", "nachtisch", "nachspeise"}, []string{"dessert", "nachtisch", "nachspeise"}},
+	{[]string{"suppe", "suppen", "soup", "soups"}, []string{"suppe", "eintopf", "soup"}},
+	{[]string{"salat", "salate", "salad", "salads"}, []string{"salat", "salad"}},
+}
+
+// categoryTerms returns the terms of the filter the keyword belongs to
+func categoryTerms(keyword string) []string {
+	keyword = strings.ToLower(keyword)
+	for _, f := range CATEGORY_FILTERS {
+		for _, k := range f.keywords {
+			if k == keyword {
+				return f.terms
+			}
+		}
+	}
+	return nil
+}
+
+// inCategory reports whether the dish's category name contains any of the lowercase terms
+func (d dish) inCategory(terms []string) bool {
+	category := strings.ToLower(d.category)
+	for _, t := range terms {
+		if category != "" && strings.Contains(category, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCategory posts the dishes of today's (or tomorrow's) plan in the category asked for like "beilagen"
+func (bot *mensabot) writeCategory(post *model.Post) {
+	offset, day := 0, tr("day.today")
+	if REG_EXP_TOMORROW.MatchString(post.Message) {
+		offset, day = 1, tr("day.tomorrow")
+	}
+
+	keyword := REG_EXP_CATEGORY.FindStringSubmatch(post.Message)[1]
+	terms := categoryTerms(keyword)
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = filterDishes(dishes, func(d dish) bool { return d.inCategory(terms) })
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("category.none", day, keyword), post.ChannelId, post.Id)
+		return
+	}
+
+	var names []string
+	for _, group := range groupByCategory(dishes) {
+		names = append(names, group[0].category)
+	}
+	bot.replyDishes(dishes, tr("category.header", day, joinWords(names), c.suffix()), post)
+}
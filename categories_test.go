@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCategoryTerms(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"beilagen", []string{"beilage", "side"}},
+		{"Sides", []string{"beilage", "side"}},
+		{"hauptgericht", []string{"hauptgericht", "hauptspeise", "main"}},
+		{"nachtisch", []string{"dessert", "nachtisch", "nachspeise"}},
+		{"SUPPE", []string{"suppe", "eintopf", "soup"}},
+		{"salads", []string{"salat", "salad"}},
+		{"pizza", nil},
+	}
+	for _, tt := range tests {
+		if got := categoryTerms(tt.keyword); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("categoryTerms(%q) = %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestInCategory(t *testing.T) {
+	tests := []struct {
+		category string
+		terms    []string
+		want     bool
+	}{
+		{"Beilagen", categoryTerms("beilagen"), true},
+		{"Side Dishes", categoryTerms("beilagen"), true},
+		{"Hauptspeise vegetarisch", categoryTerms("mains"), true},
+		{"Eintopf des Tages", categoryTerms("suppen"), true},
+		{"Dessert", categoryTerms("beilagen"), false},
+		{"", categoryTerms("beilagen"), false},
+		{"Beilagen", nil, false},
+	}
+	for _, tt := range tests {
+		if got := (dish{category: tt.category}).inCategory(tt.terms); got != tt.want {
+			t.Errorf("%q in %q = %v, want %v", tt.category, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCategory(t *testing.T) {
+	reply := dryRunReplies(t, "plan.html", "beilagen")
+	if !strings.Contains(reply, "Pommes frites") || strings.Contains(reply, "Bolognese") || strings.Contains(reply, "Grießpudding") {
+		t.Errorf("reply doesn't list just the side dishes:\n%s", reply)
+	}
+
+	reply = dryRunReplies(t, "plan.html", "suppen")
+	if want := tr("category.none", tr("day.today"), "suppen"); !strings.Contains(reply, want) {
+		t.Errorf("reply %q doesn't contain %q", reply, want)
+	}
+}
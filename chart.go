@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	CHART_WIDTH  = 600
+	CHART_HEIGHT = 300
+	CHART_MARGIN = 20
+)
+
+var (
+	CHART_BACKGROUND = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	CHART_GRID       = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	CHART_AXIS       = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	CHART_GREEN      = color.RGBA{0x4c, 0xaf, 0x50, 0xff}
+	CHART_YELLOW     = color.RGBA{0xff, 0xc1, 0x07, 0xff}
+	CHART_RED        = color.RGBA{0xf4, 0x43, 0x36, 0xff}
+)
+
+// ERR_NO_PRICES is returned when no dish has a student price to draw
+var ERR_NO_PRICES = errors.New("no dish has a student price")
+
+// priceChart draws the student prices of the dishes as PNG bar chart with a gridline per euro.
+// The bars are colored like the ratings and numbered by the order of the returned dishes, as
+// the chart has no text
+func priceChart(dishes []dish) ([]byte, []dish, error) {
+	var priced []dish
+	top := 0.0
+	for _, d := range dishes {
+		if price, ok := d.price(0); ok {
+			priced = append(priced, d)
+			top = math.Max(top, price)
+		}
+	}
+	if len(priced) == 0 {
+		return nil, nil, ERR_NO_PRICES
+	}
+	top = math.Max(math.Ceil(top), 1)
+
+	img := image.NewRGBA(image.Rect(0, 0, CHART_WIDTH, CHART_HEIGHT))
+	draw.Draw(img, img.Bounds(), &image.Uniform{CHART_BACKGROUND}, image.Point{}, draw.Src)
+
+	plot := image.Rect(CHART_MARGIN, CHART_MARGIN, CHART_WIDTH-CHART_MARGIN, CHART_HEIGHT-CHART_MARGIN)
+	y := func(price float64) int { return plot.Max.Y - int(price/top*float64(plot.Dy())) }
+	for euro := 1.0; euro <= top; euro++ {
+		draw.Draw(img, image.Rect(plot.Min.X, y(euro), plot.Max.X, y(euro)+1), &image.Uniform{CHART_GRID}, image.Point{}, draw.Src)
+	}
+
+	slot := plot.Dx() / len(priced)
+	gap := slot / 5
+	for i, d := range priced {
+		price, _ := d.price(0)
+		score, _ := d.score()
+		fill := CHART_RED
+		switch {
+		case score <= CONFIG.RatingGreen:
+			fill = CHART_GREEN
+		case score <= CONFIG.RatingYellow:
+			fill = CHART_YELLOW
+		}
+		left := plot.Min.X + i*slot + gap
+		draw.Draw(img, image.Rect(left, y(price), left+slot-2*gap, plot.Max.Y), &image.Uniform{fill}, image.Point{}, draw.Src)
+	}
+
+	draw.Draw(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+2), &image.Uniform{CHART_AXIS}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(plot.Min.X-2, plot.Min.Y, plot.Min.X, plot.Max.Y+2), &image.Uniform{CHART_AXIS}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), priced, nil
+}
+
+// uploadFile uploads data to the channel and returns the ID to attach it to a post with
+func (bot *mensabot) uploadFile(data []byte, channelID string, filename string) (string, error) {
+	if CONFIG.DryRun {
+		fmt.Fprintf(DRY_RUN_OUTPUT, "[%s, %d bytes]\n", filename, len(data))
+		return "dry-run-file", nil
+	}
+
+	var resp *model.FileUploadResponse
+	if r := withTimeout("UploadFile", func() (r *model.Response) {
+		resp, r = bot.client.UploadFile(data, channelID, filename)
+		return
+	}); r.Error != nil {
+		return "", r.Error
+	}
+	if len(resp.FileInfos) == 0 {
+		return "", errors.New("upload returned no file")
+	}
+	return resp.FileInfos[0].Id, nil
+}
+
+// writePriceChart posts today's student prices as bar chart, falling back to the plan as text if
+// the chart can't be drawn or uploaded
+func (bot *mensabot) writePriceChart(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), post.ChannelId, post.Id)
+		return
+	}
+
+	data, priced, err := priceChart(dishes)
+	var fileID string
+	if err == nil {
+		fileID, err = bot.uploadFile(data, post.ChannelId, "prices.png")
+	}
+	if err != nil {
+		slog.Warn("Failed to post price chart, falling back to text", "error", err)
+		bot.replyDishes(dishes, tr("plan.today", c.suffix()), post)
+		return
+	}
+
+	var legend bytes.Buffer
+	legend.WriteString(tr("chart.header", c.suffix()) + "\n\n")
+	for i, d := range priced {
+		legend.WriteString(strconv.Itoa(i+1) + ". " + d.displayName() + " - " + d.prices[0] + "\n")
+	}
+	bot.createPost(&model.Post{
+		ChannelId: post.ChannelId,
+		Message:   legend.String(),
+		RootId:    post.Id,
+		FileIds:   []string{fileID},
+	})
+}
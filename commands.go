@@ -0,0 +1,199 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// command is an entry of COMMANDS. The pattern points to the command's regex variable, so
+// keywords configured later take effect
+type command struct {
+	name    string
+	pattern **regexp.Regexp
+	run     func(bot *mensabot, post *model.Post)
+}
+
+// COMMANDS are tried in order, the first one matching a post handles it. Order matters where
+// keywords overlap, e.g. 'feedback' may be followed by any other keyword
+var COMMANDS = []command{
+	// If the message starts with 'order' and a subcommand, handle the group order. This goes first
+	// since poll options and order texts may contain any other keyword, e.g. 'order poll Pizza | Salad'.
+	// The pattern is anchored to the start of the message, so it never takes keywords from other commands
+	{"order", &REG_EXP_ORDER, (*mensabot).handleOrder},
+	// If you see 'feedback' followed by a text, forward it to the debug channel. This goes before
+	// the other commands since the text may contain any of their keywords
+	{"feedback", &REG_EXP_FEEDBACK, (*mensabot).forwardFeedback},
+	// If you see any word matching 'alive'/'running'/'up'/'version' then respond with status
+	{"status", &REG_EXP_STATUS, func(bot *mensabot, post *model.Post) { bot.writeStatus(post.ChannelId, post.Id) }},
+	// If an admin says 'reload', read the config file again
+	{"reload", &REG_EXP_RELOAD, (*mensabot).handleReload},
+	// If you see 'öffnungszeiten'/'geöffnet'/'opening hours', post them and whether the canteen is open now
+	{"hours", &REG_EXP_HOURS, func(bot *mensabot, post *model.Post) { bot.writeOpeningHours(post.ChannelId, post.Id) }},
+	// If you see 'lieblings woche'/'favorites week', list the days this week serving a favorite of the user
+	{"favorite_week", &REG_EXP_FAVORITE_WEEK, (*mensabot).writeFavoriteWeek},
+	// If you see 'favorit add/remove/list', manage the personal favorites of the user
+	{"favorite", &REG_EXP_FAVORITE, (*mensabot).handleFavorite},
+	// If you see 'lieblingsgerichte'/'favorites', list the configured and the user's own favorites
+	{"favorites", &REG_EXP_FAVORITES, (*mensabot).writeFavorites},
+	// If you see 'nurveggie an/aus', hide or show the dishes with meat or fish in this channel
+	{"veggie_only", &REG_EXP_VEGGIE_ONLY, (*mensabot).handleVeggieOnly},
+	// If you see 'abo an/aus', (un)subscribe the user from the scheduled plan as direct message
+	{"subscription", &REG_EXP_SUBSCRIPTION, (*mensabot).handleSubscription},
+	// If you see 'profil', show or change the user's dietary profile applied to plain plan requests
+	{"profile", &REG_EXP_PROFILE, (*mensabot).handleProfile},
+	// If you see 'suche'/'search' followed by a term, look for it in today's and tomorrow's plans
+	{"search", &REG_EXP_SEARCH, (*mensabot).writeSearch},
+	// If you see 'nächster'/'next' followed by a diet, look for the next day serving a matching dish
+	{"next", &REG_EXP_NEXT_DAY, (*mensabot).writeNextDay},
+	// If you see 'neu morgen'/'new tomorrow', post tomorrow's dishes which aren't served today
+	{"new", &REG_EXP_NEW_TOMORROW, (*mensabot).writeNewTomorrow},
+	// If you see 'unter'/'under' followed by an amount, post the dishes cheaper than that
+	{"under", &REG_EXP_UNDER, (*mensabot).writeDishesUnder},
+	// If you see 'vegan woche'/'vegan week', post the vegan dishes of each day from Monday to Friday
+	{"vegan_week", &REG_EXP_VEGAN_WEEK, (*mensabot).writeVeganWeek},
+	// If you see 'beilagen'/'hauptgerichte' or another category, post the dishes of matching counters
+	{"category", &REG_EXP_CATEGORY, (*mensabot).writeCategory},
+	// If you see 'vegan' or 'vegetarisch'/'veggie', post only the matching dishes of today's (or tomorrow's) plan
+	{"filter", &REG_EXP_VEGAN, (*mensabot).writeFilteredDishes},
+	{"filter", &REG_EXP_VEGETARIAN, (*mensabot).writeFilteredDishes},
+	// If you see 'ohne'/'without' followed by additive numbers, post the dishes free of those additives
+	{"without", &REG_EXP_WITHOUT, (*mensabot).writeDishesWithout},
+	// If you see 'kalorien'/'calories'/'kcal', post today's plan sorted by calories
+	{"calories", &REG_EXP_CALORIES, (*mensabot).writeDishesByCalories},
+	// If you see 'günstig'/'billig'/'cheap', post today's plan sorted by the student price
+	{"cheap", &REG_EXP_CHEAP, (*mensabot).writeDishesByPrice},
+	// If you see 'zufall'/'random'/'egal', pick one of today's dishes
+	{"random", &REG_EXP_RANDOM, (*mensabot).writeRandomDish},
+	// If you see 'übersicht'/'overview'/'summary', count the dietary categories of today's dishes
+	{"summary", &REG_EXP_SUMMARY, (*mensabot).writeSummary},
+	// If you see 'preise'/'prices', compare today's prices with the last time each dish was served
+	{"prices", &REG_EXP_PRICES, (*mensabot).writePriceChanges},
+	// If you see 'durchschnitt'/'average', post the lowest, highest and average student price of today
+	{"average", &REG_EXP_AVERAGE, (*mensabot).writePriceAverage},
+	// If you see 'statistik'/'statistics', summarize this month's vegan and vegetarian options
+	{"statistics", &REG_EXP_STATISTICS, (*mensabot).writeStatistics},
+	// If you see 'diagramm'/'chart', post today's student prices as bar chart
+	{"chart", &REG_EXP_CHART, (*mensabot).writePriceChart},
+	// If you see 'csv', post today's (or tomorrow's) plan as CSV for spreadsheets
+	{"csv", &REG_EXP_CSV, (*mensabot).writeCSV},
+	// If you see any word matching 'heute', 'today' or 'hunger', post today's canteen plan
+	// Adding 'refresh' to the command bypasses the plan cache
+	{"today", &REG_EXP_TODAY, (*mensabot).writeToday},
+	// If you see any word matching 'morgen' or 'tomorrow', post tomorrow's canteen plan
+	{"tomorrow", &REG_EXP_TOMORROW, (*mensabot).writeTomorrow},
+	// If you see 'woche'/'week', post the plans from Monday to Friday in one message
+	{"week", &REG_EXP_WEEK, (*mensabot).writeWeek},
+	// If you see any weekday name, post the canteen plan of its next occurrence
+	{"weekday", &REG_EXP_WEEKDAY, (*mensabot).writeWeekday},
+	// If you see a date like '24.12.' or '24.12.2024', post the canteen plan of that day
+	{"date", &REG_EXP_DATE, (*mensabot).writeDate},
+	// If you see 'mensen'/'canteens', post the configured canteens
+	{"canteens", &REG_EXP_CANTEENS, func(bot *mensabot, post *model.Post) { bot.writeCanteens(post.ChannelId, post.Id) }},
+	// If you see 'nummer'/'zusatzstoff' followed by numbers, explain just those additives
+	{"additive", &REG_EXP_ADDITIVE_LOOKUP, (*mensabot).writeAdditives},
+	// If you see any word matching 'legend(e)', post the legend of the dish flags
+	{"legend", &REG_EXP_LEGEND, func(bot *mensabot, post *model.Post) { bot.writeLegend(post.ChannelId, post.Id) }},
+	// If you see any word matching 'zusatzstoff(e)', 'nummer(n)' or 'additives', post all additives
+	{"additives", &REG_EXP_ADDITIVE_LIST, func(bot *mensabot, post *model.Post) { bot.writeAdditiveList(post.ChannelId, post.Id) }},
+	// If you see any word matching 'command' or 'help', post available commands
+	{"help", &REG_EXP_HELP, func(bot *mensabot, post *model.Post) { bot.writeHelp(post.ChannelId, post.Id) }},
+	{"thanks", &REG_EXP_THANKS, func(bot *mensabot, post *model.Post) { bot.writeMyPleasure(post.ChannelId, post.Id) }},
+	// If you see 'speiseplan', 'menü' or 'essen' without any more specific keyword, post today's
+	// canteen plan. This goes last as these words also show up in orders and other commands
+	{"today", &REG_EXP_MENU, (*mensabot).writeToday},
+}
+
+// matchCommand returns the first of COMMANDS matching msg or nil if none does
+func matchCommand(msg string) *command {
+	for i := range COMMANDS {
+		if (*COMMANDS[i].pattern).MatchString(msg) {
+			return &COMMANDS[i]
+		}
+	}
+	return nil
+}
+
+// startCommand logs and counts the command and reports whether the user may run it right now
+func (bot *mensabot) startCommand(command string, post *model.Post) bool {
+	slog.Info("Handling command", "command", command, "channel_id", post.ChannelId, "user_id", post.UserId)
+	METRICS_COMMANDS.inc(command)
+
+	if !RATE_LIMITER.allow(post.UserId, command) {
+		slog.Info("Rate limited command", "command", command, "channel_id", post.ChannelId, "user_id", post.UserId)
+		bot.sendMessage(tr("rate_limited"), post.ChannelId, post.Id)
+		return false
+	}
+	return true
+}
+
+// runCommand replies to the first command matching the post and reports whether it ran, i.e. it
+// wasn't rate limited
+func (bot *mensabot) runCommand(post *model.Post) bool {
+	if c := matchCommand(post.Message); c != nil {
+		if !bot.startCommand(c.name, post) {
+			return false
+		}
+		c.run(bot, post)
+		return true
+	}
+
+	// Give typos like 'heuate' a second chance before giving up
+	if corrected, ok := correctTypos(post.Message); ok {
+		slog.Info("Correcting typos in command", "message", post.Message, "corrected", corrected)
+		post.Message = corrected
+		return bot.runCommand(post)
+	}
+
+	if !bot.startCommand("unknown", post) {
+		return false
+	}
+	// If nothing matched post a generic message
+	bot.sendMessage(tr("unknown"), post.ChannelId, post.Id)
+	return true
+}
+
+// writeTomorrow posts tomorrow's canteen plan
+func (bot *mensabot) writeTomorrow(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 1, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.tomorrow")), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, tr("plan.tomorrow", c.suffix()), post)
+}
+
+// writeWeekday posts the canteen plan of the next occurrence of the weekday named in the post
+func (bot *mensabot) writeWeekday(post *model.Post) {
+	day := WEEKDAYS[strings.ToLower(REG_EXP_WEEKDAY.FindStringSubmatch(post.Message)[1])]
+	if day == time.Saturday || day == time.Sunday {
+		bot.sendMessage(closedMessage(tr("when.weekday", weekdayName(day))), post.ChannelId, post.Id)
+		return
+	}
+
+	offset := weekdayOffset(day)
+	if CONFIG.UseMafiasiMensa && offset > 1 {
+		bot.sendMessage(tr("plan.mafiasi"), post.ChannelId, post.Id)
+		return
+	}
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.weekday", weekdayName(day))), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, tr("plan.weekday", weekdayName(day), c.suffix()), post)
+}
@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+// commandName returns the name of the command matching msg or "" if none does
+func commandName(msg string) string {
+	if c := matchCommand(msg); c != nil {
+		return c.name
+	}
+	return ""
+}
+
+func TestMenuKeywords(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"speiseplan", "today"},
+		{"Was steht heute auf dem Speiseplan?", "today"},
+		{"menü", "today"},
+		{"@mensabot Menu bitte", "today"},
+		{"essen?", "today"},
+		// More specific keywords win over the menu synonyms
+		{"speiseplan morgen", "tomorrow"},
+		{"essen vegan", "filter"},
+		{"Menü legende", "legend"},
+		{"@mensabot order submit Essen", "order"},
+		// Words merely containing the synonyms don't count
+		{"vergessen", ""},
+		{"menükarte", ""},
+	}
+	for _, tt := range tests {
+		if got := commandName(tt.msg); got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCommand(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		// Orders and feedback carry free text containing other keywords
+		{"order poll Pizza | Pasta | Salad", "order"},
+		{"@mensabot order submit Salat mit Beilagen", "order"},
+		{"@mensabot order open Essen heute um 12", "order"},
+		{"feedback heute war der Speiseplan falsch", "feedback"},
+		{"feedback order open geht nicht", "feedback"},
+		{"@mensabot version", "status"},
+		{"reload", "reload"},
+		{"öffnungszeiten", "hours"},
+		{"lieblings woche", "favorite_week"},
+		{"favorit add Pizza", "favorite"},
+		{"favorites", "favorites"},
+		{"nurveggie an", "veggie_only"},
+		{"abo an", "subscription"},
+		{"profil vegan", "profile"},
+		{"suche Pizza", "search"},
+		{"nächster veganer Tag", "next"},
+		{"neu morgen", "new"},
+		{"unter 2,50", "under"},
+		{"vegan woche", "vegan_week"},
+		{"beilagen", "category"},
+		{"vegan", "filter"},
+		{"veggie morgen", "filter"},
+		{"ohne 20, 21", "without"},
+		{"kalorien", "calories"},
+		{"günstig", "cheap"},
+		{"egal", "random"},
+		{"übersicht", "summary"},
+		{"preise", "prices"},
+		{"durchschnitt", "average"},
+		{"statistik", "statistics"},
+		{"diagramm", "chart"},
+		{"csv morgen", "csv"},
+		{"heute", "today"},
+		{"morgen", "tomorrow"},
+		{"woche", "week"},
+		{"montag", "weekday"},
+		{"24.12.", "date"},
+		{"mensen", "canteens"},
+		{"nummer 20 21", "additive"},
+		{"legende", "legend"},
+		{"zusatzstoffe", "additives"},
+		{"help", "help"},
+		{"danke", "thanks"},
+		{"speiseplan", "today"},
+		{"hallo", ""},
+	}
+	for _, tt := range tests {
+		if got := commandName(tt.msg); got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
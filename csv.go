@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// dishesCSV renders dishes as CSV with a header row, one column per price category and flag.
+// Writing to a buffer can't fail, so the errors of the csv writer are ignored
+func dishesCSV(dishes []dish) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"name", "category"}
+	header = append(header, CONFIG.PriceLabels...)
+	header = append(header, "vegetarian", "vegan", "beef", "pork", "fish", "chicken", "lactosefree", "additives", "calories")
+	w.Write(header)
+
+	for _, d := range dishes {
+		additives := make([]string, len(d.additives))
+		for i, a := range d.additives {
+			additives[i] = strconv.Itoa(a)
+		}
+		calories := ""
+		if d.calories > 0 {
+			calories = strconv.Itoa(d.calories)
+		}
+
+		record := []string{d.name, d.category}
+		for i := range CONFIG.PriceLabels {
+			price := ""
+			if i < len(d.prices) {
+				price = d.prices[i]
+			}
+			record = append(record, price)
+		}
+		record = append(record,
+			strconv.FormatBool(d.isVegetarian),
+			strconv.FormatBool(d.isVegan),
+			strconv.FormatBool(d.containsBeef),
+			strconv.FormatBool(d.containsPork),
+			strconv.FormatBool(d.containsFish),
+			strconv.FormatBool(d.containsChicken),
+			strconv.FormatBool(d.lactoseFree),
+			strings.Join(additives, ","),
+			calories,
+		)
+		w.Write(record)
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+// writeCSV posts today's (or tomorrow's) plan as CSV in a code block for pasting into spreadsheets
+func (bot *mensabot) writeCSV(post *model.Post) {
+	offset, header := 0, "plan.today"
+	if REG_EXP_TOMORROW.MatchString(post.Message) {
+		offset, header = 1, "plan.tomorrow"
+	}
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		when := tr("when.today")
+		if offset == 1 {
+			when = tr("when.tomorrow")
+		}
+		bot.sendMessage(closedMessage(when), post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), post.ChannelId, post.Id)
+		return
+	}
+	bot.sendMessage(tr(header, c.suffix())+"\n\n```csv\n"+dishesCSV(dishes)+"```", post.ChannelId, post.Id)
+}
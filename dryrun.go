@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	DRY_RUN_USER_ID    = "dry-run-user"
+	DRY_RUN_CHANNEL_ID = "dry-run-channel"
+)
+
+// DRY_RUN_OUTPUT receives the posts of a dry run
+var DRY_RUN_OUTPUT io.Writer = os.Stdout
+
+// newDryRunBot creates a bot which is not connected to Mattermost and prints its replies instead
+func newDryRunBot(cfg *config) *mensabot {
+	return &mensabot{
+		user:         &model.User{Id: "dry-run-bot", Username: "mensabot"},
+		team:         &model.Team{Id: "dry-run-team", Name: cfg.TeamName},
+		channelDebug: &model.Channel{Id: DRY_RUN_CHANNEL_ID, Name: cfg.ChannelNameDebug},
+	}
+}
+
+// runDryRun handles every line read from stdin as a command posted to the debug channel
+func (bot *mensabot) runDryRun() {
+	slog.Info("Running in dry-run mode, type commands and end with Ctrl-D")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for n := 1; scanner.Scan(); n++ {
+		post := &model.Post{
+			Id:        "dry-run-post-" + strconv.Itoa(n),
+			UserId:    DRY_RUN_USER_ID,
+			ChannelId: DRY_RUN_CHANNEL_ID,
+			Message:   scanner.Text(),
+		}
+		bot.handleCommand(post)
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Failed to read commands", "error", err)
+	}
+}
+
+func printDryRunPost(post *model.Post) {
+	msg := post.Message
+	if attachments, ok := post.GetProp("attachments").([]*model.SlackAttachment); ok {
+		for _, a := range attachments {
+			msg += "\n" + a.Fallback
+		}
+	}
+	fmt.Fprintf(DRY_RUN_OUTPUT, "--- [channel %s, reply to %s]\n%s\n\n", post.ChannelId, post.RootId, msg)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// favoriteStore holds the personal favorite terms of each user, keyed by user ID
+type favoriteStore struct {
+	sync.Mutex
+	path  string
+	terms map[string][]string
+}
+
+var FAVORITES = favoriteStore{terms: make(map[string][]string)}
+
+// load restores the favorites from path and persists every following change there
+func (s *favoriteStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	terms := make(map[string][]string)
+	if err := loadJSON(path, &terms); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable favorites file", "error", err)
+		}
+		return
+	}
+	s.terms = terms
+	slog.Info("Restored personal favorites", "path", path, "users", len(terms))
+}
+
+// save must be called with the lock held
+func (s *favoriteStore) save() {
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.terms); err != nil {
+		slog.Error("Failed to persist personal favorites", "error", err)
+	}
+}
+
+// add stores term for the user and reports whether it was new
+func (s *favoriteStore) add(userID string, term string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	term = strings.ToLower(term)
+	for _, t := range s.terms[userID] {
+		if t == term {
+			return false
+		}
+	}
+	s.terms[userID] = append(s.terms[userID], term)
+	s.save()
+	return true
+}
+
+// remove deletes term from the user's favorites and reports whether it was present
+func (s *favoriteStore) remove(userID string, term string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	term = strings.ToLower(term)
+	terms := s.terms[userID]
+	for i, t := range terms {
+		if t == term {
+			terms = append(terms[:i:i], terms[i+1:]...)
+			if len(terms) == 0 {
+				delete(s.terms, userID)
+			} else {
+				s.terms[userID] = terms
+			}
+			s.save()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *favoriteStore) get(userID string) []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string(nil), s.terms[userID]...)
+}
+
+func (s *favoriteStore) users() (userIDs []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for userID := range s.terms {
+		userIDs = append(userIDs, userID)
+	}
+	return
+}
+
+// favoriteNames returns the names of the dishes matching any of the favorite terms
+func favoriteNames(dishes []dish, terms []string) (names []string) {
+	for _, d := range dishes {
+		if d.matchesAny(terms) {
+			names = append(names, d.name)
+		}
+	}
+	return
+}
+
+func (bot *mensabot) handleFavorite(post *model.Post) {
+	match := REG_EXP_FAVORITE.FindStringSubmatch(post.Message)
+	cmd, term := strings.ToLower(match[1]), strings.TrimSpace(match[2])
+
+	if cmd == "list" {
+		terms := FAVORITES.get(post.UserId)
+		if len(terms) == 0 {
+			bot.sendMessage(tr("favorites.none"), post.ChannelId, post.Id)
+			return
+		}
+		bot.sendMessage(tr("favorites.list", strings.Join(terms, ", ")), post.ChannelId, post.Id)
+		return
+	}
+
+	if term == "" {
+		bot.sendMessage(tr("favorites.which", cmd), post.ChannelId, post.Id)
+		return
+	}
+
+	switch cmd {
+	case "add":
+		if FAVORITES.add(post.UserId, term) {
+			bot.sendMessage(tr("favorites.added", term), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("favorites.exists", term), post.ChannelId, post.Id)
+		}
+	case "remove":
+		if FAVORITES.remove(post.UserId, term) {
+			bot.sendMessage(tr("favorites.removed", term), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("favorites.missing", term), post.ChannelId, post.Id)
+		}
+	}
+}
+
+// writeFavorites lists the favorites marked on the plans for the user, i.e. the configured ones
+// and the user's own
+func (bot *mensabot) writeFavorites(post *model.Post) {
+	personal := FAVORITES.get(post.UserId)
+	if len(CONFIG.Favorites) == 0 && len(personal) == 0 {
+		bot.sendMessage(tr("favorites.effective.none"), post.ChannelId, post.Id)
+		return
+	}
+
+	msg := ""
+	if len(CONFIG.Favorites) > 0 {
+		msg += tr("favorites.effective.config") + "\n"
+		for _, term := range CONFIG.Favorites {
+			msg += "- " + term + "\n"
+		}
+	}
+	if len(personal) > 0 {
+		if msg != "" {
+			msg += "\n"
+		}
+		msg += tr("favorites.effective.user") + "\n"
+		for _, term := range personal {
+			msg += "- " + term + "\n"
+		}
+	}
+	bot.sendMessage(msg, post.ChannelId, post.Id)
+}
+
+// writeFavoriteHighlight points the user to their favorites on today's plan, if there are any
+func (bot *mensabot) writeFavoriteHighlight(dishes []dish, userID string, channelID string, replyToID string) {
+	terms := append(FAVORITES.get(userID), CONFIG.Favorites...)
+	if names := favoriteNames(dishes, terms); len(names) > 0 {
+		bot.sendMessage(tr("favorites.today.user", strings.Join(names, ", ")), channelID, replyToID)
+	}
+}
+
+// writeFavoriteAnnouncements posts the favorites on today's plan to the channel, mentioning
+// each user with matching personal favorites
+func (bot *mensabot) writeFavoriteAnnouncements(dishes []dish, channelID string, replyToID string) {
+	if names := favoriteNames(dishes, CONFIG.Favorites); len(names) > 0 {
+		bot.sendMessage(tr("favorites.today", strings.Join(names, ", ")), channelID, replyToID)
+	}
+
+	for _, userID := range FAVORITES.users() {
+		names := favoriteNames(dishes, FAVORITES.get(userID))
+		if len(names) == 0 {
+			continue
+		}
+		user := bot.getUser(userID)
+		bot.sendMessage("@"+user.Username+" "+tr("favorites.today.user", strings.Join(names, ", ")), channelID, replyToID)
+	}
+}
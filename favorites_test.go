@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFavoriteAnnouncements(t *testing.T) {
+	out := useDryRun(t, "plan.html")
+	FAVORITES.add("alice-id", "curry")
+	FAVORITES.add("bob-id", "schnitzel")
+	t.Cleanup(func() {
+		FAVORITES.remove("alice-id", "curry")
+		FAVORITES.remove("bob-id", "schnitzel")
+	})
+
+	bot := newDryRunBot(&CONFIG)
+	bot.writeFavoriteAnnouncements(parseFixture(t, "plan.html"), DRY_RUN_CHANNEL_ID, "")
+
+	reply := out.String()
+	if want := "@alice-id " + tr("favorites.today.user", "Gemüse-Curry mit Reis"); !strings.Contains(reply, want) {
+		t.Errorf("announcements miss %q:\n%s", want, reply)
+	}
+	if strings.Contains(reply, "bob-id") {
+		t.Errorf("announced bob without a favorite on the plan:\n%s", reply)
+	}
+}
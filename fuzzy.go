@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// FUZZY_MIN_LENGTH keeps short words like "da" from being corrected to a keyword
+const FUZZY_MIN_LENGTH = 4
+
+// FUZZY_KEYWORDS are the plain words of the default command regexes typos get corrected to
+var FUZZY_KEYWORDS = []string{
+	"heute", "today", "hunger", "speiseplan", "menü", "menu", "essen", "morgen", "tomorrow",
+	"vegetarisch", "vegetarian", "veggie", "vegan",
+	"kalorien", "calories", "günstig", "billig", "cheap", "zufall", "random",
+	"woche", "week", "übersicht", "overview", "summary", "preise", "prices",
+	"montag", "dienstag", "mittwoch", "donnerstag", "freitag",
+	"monday", "tuesday", "wednesday", "thursday", "friday",
+	"mensen", "canteens", "legende", "legend", "zusatzstoffe", "help", "commands",
+}
+
+// fuzzyKeywords returns the default keywords and the configured ones which are plain words
+func fuzzyKeywords() []string {
+	keywords := append([]string(nil), FUZZY_KEYWORDS...)
+	for _, words := range CONFIG.Keywords {
+		for _, w := range words {
+			if regexp.QuoteMeta(w) == w && len([]rune(w)) >= FUZZY_MIN_LENGTH {
+				keywords = append(keywords, strings.ToLower(w))
+			}
+		}
+	}
+	return keywords
+}
+
+// correctTypos replaces the words of msg which are close to a keyword by that keyword and
+// reports whether anything was replaced
+func correctTypos(msg string) (string, bool) {
+	if CONFIG.FuzzyDistance <= 0 {
+		return msg, false
+	}
+
+	keywords := fuzzyKeywords()
+	corrected := msg
+	for _, word := range strings.FieldsFunc(msg, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if len([]rune(word)) < FUZZY_MIN_LENGTH {
+			continue
+		}
+
+		lower := strings.ToLower(word)
+		best, bestDistance := "", CONFIG.FuzzyDistance+1
+		for _, k := range keywords {
+			if d := levenshtein(lower, k); d < bestDistance {
+				best, bestDistance = k, d
+			}
+		}
+		// Exact matches already had their chance with the regexes
+		if best != "" && bestDistance > 0 {
+			corrected = strings.Replace(corrected, word, best, 1)
+		}
+	}
+	return corrected, corrected != msg
+}
+
+// levenshtein returns the number of single rune edits needed to turn a into b
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestCorrectTypos(t *testing.T) {
+	tests := []struct {
+		msg      string
+		distance int
+		want     string
+		wantOK   bool
+	}{
+		{"Was gibt es heuate?", 1, "Was gibt es heute?", true},
+		{"@mensabot tomorow", 1, "@mensabot tomorrow", true},
+		{"vegtarisch bitte", 1, "vegetarisch bitte", true},
+		{"Heuate", 1, "heute", true},
+		{"montga", 1, "montga", false},
+		{"montga", 2, "montag", true},
+		{"heuate", 0, "heuate", false},
+		// Exact keywords and short words are left alone
+		{"heute", 1, "heute", false},
+		{"da wo", 1, "da wo", false},
+		{"hallo zusammen", 1, "hallo zusammen", false},
+	}
+
+	for _, tt := range tests {
+		cfg := defaultConfig()
+		cfg.FuzzyDistance = tt.distance
+		useConfig(t, cfg)
+
+		if got, ok := correctTypos(tt.msg); got != tt.want || ok != tt.wantOK {
+			t.Errorf("correctTypos(%q) with distance %d = %q, %v, want %q, %v", tt.msg, tt.distance, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"heute", "heute", 0},
+		{"heuate", "heute", 1},
+		{"kitten", "sitting", 3},
+		{"menü", "menu", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/1wilkens/mensabot
 
-go 1.15
+go 1.21
 
 require (
 	github.com/BurntSushi/toml v0.4.1
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// HANDLED_POSTS_SIZE bounds the number of remembered post IDs, the least recently seen are
+// forgotten first
+const HANDLED_POSTS_SIZE = 1000
+
+// handledPosts remembers the IDs of the last handled posts in least recently used order, so
+// repeated events for the same post, e.g. after a reconnect, don't trigger a second reply
+type handledPosts struct {
+	sync.Mutex
+	seen  map[string]*list.Element
+	order *list.List
+}
+
+var HANDLED_POSTS = handledPosts{seen: make(map[string]*list.Element), order: list.New()}
+
+// markHandled records the post and reports whether it was new. Seeing a post again keeps it
+// remembered for longer, as further duplicates are likely
+func (h *handledPosts) markHandled(postID string) bool {
+	h.Lock()
+	defer h.Unlock()
+
+	if e, ok := h.seen[postID]; ok {
+		h.order.MoveToFront(e)
+		return false
+	}
+
+	h.seen[postID] = h.order.PushFront(postID)
+	if h.order.Len() > HANDLED_POSTS_SIZE {
+		oldest := h.order.Back()
+		h.order.Remove(oldest)
+		delete(h.seen, oldest.Value.(string))
+	}
+	return true
+}
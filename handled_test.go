@@ -0,0 +1,72 @@
+package main
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func newHandledPosts() *handledPosts {
+	return &handledPosts{seen: make(map[string]*list.Element), order: list.New()}
+}
+
+func TestMarkHandledConcurrent(t *testing.T) {
+	h := newHandledPosts()
+
+	// Every post is delivered by several goroutines at once, only one of them may handle it
+	const posts, deliveries = 100, 8
+	var handled atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < posts*deliveries; i++ {
+		wg.Add(1)
+		go func(postID string) {
+			defer wg.Done()
+			if h.markHandled(postID) {
+				handled.Add(1)
+			}
+		}("post-" + strconv.Itoa(i%posts))
+	}
+	wg.Wait()
+
+	if got := handled.Load(); got != posts {
+		t.Errorf("handled %d posts, want %d", got, posts)
+	}
+}
+
+func TestMarkHandledEviction(t *testing.T) {
+	h := newHandledPosts()
+	for i := 0; i < HANDLED_POSTS_SIZE; i++ {
+		if !h.markHandled("post-" + strconv.Itoa(i)) {
+			t.Fatalf("post-%d reported as handled before", i)
+		}
+	}
+
+	// Seeing the oldest post again keeps it, so the next oldest gets evicted instead
+	if h.markHandled("post-0") {
+		t.Error("post-0 reported as new")
+	}
+	if !h.markHandled("post-new") {
+		t.Error("post-new reported as handled before")
+	}
+	if h.order.Len() != HANDLED_POSTS_SIZE || len(h.seen) != HANDLED_POSTS_SIZE {
+		t.Errorf("remembering %d and %d posts, want %d", h.order.Len(), len(h.seen), HANDLED_POSTS_SIZE)
+	}
+	if h.markHandled("post-0") {
+		t.Error("post-0 was evicted although it was seen recently")
+	}
+	if !h.markHandled("post-1") {
+		t.Error("post-1 wasn't evicted although it was the least recently seen")
+	}
+}
+
+// forgetHandledPosts starts the test without any handled posts, so posts of earlier runs of the
+// test, e.g. with -count, aren't ignored as duplicates
+func forgetHandledPosts(t *testing.T) {
+	t.Helper()
+	HANDLED_POSTS.Lock()
+	defer HANDLED_POSTS.Unlock()
+	HANDLED_POSTS.seen = make(map[string]*list.Element)
+	HANDLED_POSTS.order = list.New()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// openingHours is the time span a canteen is open on a day, in minutes after midnight
+type openingHours struct {
+	from int
+	to   int
+}
+
+// parseOpeningHours parses spans like "11:00-14:30"
+func parseOpeningHours(span string) (openingHours, error) {
+	parts := strings.Split(span, "-")
+	if len(parts) != 2 {
+		return openingHours{}, fmt.Errorf("'%s' is not a time span like 11:00-14:30", span)
+	}
+	from, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return openingHours{}, err
+	}
+	to, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return openingHours{}, err
+	}
+	if !to.After(from) {
+		return openingHours{}, fmt.Errorf("'%s' ends before it starts", span)
+	}
+	return openingHours{from.Hour()*60 + from.Minute(), to.Hour()*60 + to.Minute()}, nil
+}
+
+// contains reports whether the canteen is open at the time of day of t
+func (h openingHours) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	return minute >= h.from && minute < h.to
+}
+
+func (h openingHours) String() string {
+	return fmt.Sprintf("%02d:%02d - %02d:%02d", h.from/60, h.from%60, h.to/60, h.to%60)
+}
+
+// openingHoursOn returns the configured opening hours on the weekday, false if the canteen is closed then.
+// The config was validated on startup, so the spans parse
+func openingHoursOn(day time.Weekday) (openingHours, bool) {
+	span, ok := CONFIG.OpeningHours[strings.ToLower(day.String())]
+	if !ok {
+		return openingHours{}, false
+	}
+	hours, err := parseOpeningHours(span)
+	return hours, err == nil
+}
+
+// writeOpeningHours posts the opening hours of the week and whether the canteen is open right now
+func (bot *mensabot) writeOpeningHours(channelID string, replyToID string) {
+	if len(CONFIG.OpeningHours) == 0 {
+		bot.sendMessage(tr("hours.unknown"), channelID, replyToID)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(tr("hours.header") + "\n\n")
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		hours, ok := openingHoursOn(day)
+		if !ok {
+			buf.WriteString("- " + weekdayName(day) + ": " + tr("hours.closed") + "\n")
+			continue
+		}
+		buf.WriteString("- " + weekdayName(day) + ": " + hours.String() + "\n")
+	}
+
+	now := time.Now()
+	if location, err := time.LoadLocation(SCHEDULE_TIMEZONE); err == nil {
+		now = now.In(location)
+	}
+	if hours, ok := openingHoursOn(now.Weekday()); ok && hours.contains(now) {
+		buf.WriteString("\n" + tr("hours.open", fmt.Sprintf("%02d:%02d", hours.to/60, hours.to%60)))
+	} else {
+		buf.WriteString("\n" + tr("hours.now_closed"))
+	}
+	bot.sendMessage(buf.String(), channelID, replyToID)
+}
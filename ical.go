@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ICAL_LINE_LENGTH is the maximum length of a content line in octets before it has to be folded
+const ICAL_LINE_LENGTH = 75
+
+// ICAL_ESCAPER escapes the characters with a special meaning in iCalendar text values
+var ICAL_ESCAPER = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICalLine writes a content line, folding it into continuation lines starting with a space
+// without splitting UTF-8 sequences
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := ICAL_LINE_LENGTH
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = ICAL_LINE_LENGTH - 1
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+// weekCalendar renders the plans of the week as iCalendar with an all-day event per open day
+func weekCalendar(c canteen, plans []dayPlan) string {
+	location, err := time.LoadLocation(SCHEDULE_TIMEZONE)
+	if err != nil {
+		location = time.Local
+	}
+	now := time.Now().In(location)
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	var buf bytes.Buffer
+	writeICalLine(&buf, "BEGIN:VCALENDAR")
+	writeICalLine(&buf, "VERSION:2.0")
+	writeICalLine(&buf, "PRODID:-//mensabot//"+CONFIG.DisplayName+"//"+strings.ToUpper(CONFIG.Language))
+	writeICalLine(&buf, "X-WR-CALNAME:"+ICAL_ESCAPER.Replace(tr("ical.summary", c.suffix())))
+	for _, p := range plans {
+		// Closed days and days which couldn't be fetched get no event
+		if p.err != nil || len(p.dishes) == 0 {
+			continue
+		}
+
+		day := now.AddDate(0, 0, p.offset)
+		lines := make([]string, len(p.dishes))
+		for i, d := range p.dishes {
+			lines[i] = "- " + d.displayName() + " (" + strings.Join(d.priceSlots(), " / ") + ")"
+		}
+
+		writeICalLine(&buf, "BEGIN:VEVENT")
+		writeICalLine(&buf, "UID:"+day.Format("20060102")+"-"+c.ID+"@mensabot")
+		writeICalLine(&buf, "DTSTAMP:"+stamp)
+		writeICalLine(&buf, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+		writeICalLine(&buf, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		writeICalLine(&buf, "SUMMARY:"+ICAL_ESCAPER.Replace(tr("ical.summary", c.suffix())))
+		writeICalLine(&buf, "DESCRIPTION:"+ICAL_ESCAPER.Replace(strings.Join(lines, "\n")))
+		writeICalLine(&buf, "TRANSP:TRANSPARENT")
+		writeICalLine(&buf, "END:VEVENT")
+	}
+	writeICalLine(&buf, "END:VCALENDAR")
+	return buf.String()
+}
+
+// handleCalendar serves the plans of the week as iCalendar feed, the canteen can be picked by name
+// with the "canteen" query parameter
+func handleCalendar(w http.ResponseWriter, r *http.Request) {
+	c := selectCanteen(r.URL.Query().Get("canteen"))
+	plans := getWeekPlans(c.ID)
+	for _, p := range plans {
+		if p.err != nil {
+			slog.Error("Failed to get canteen plan for calendar feed", "canteen", c.ID, "day", p.day, "error", p.err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(weekCalendar(c, plans)))
+}
@@ -2,15 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -23,26 +33,170 @@ import (
 const (
 	VERSION = "v0.4"
 
-	CANTEEN_URL_TODAY    = "http://speiseplan.studierendenwerk-hamburg.de/de/580/2018/0/"
-	CANTEEN_URL_TOMORROW = "http://speiseplan.studierendenwerk-hamburg.de/de/580/2018/99/"
+	ADDITIVE_MILK = 20
+
+	// Marks prices missing from the plan in dish.priceValues
+	PRICE_MISSING = -1
+
+	EMOJI_WORKING = "hourglass_flowing_sand"
+	EMOJI_DONE    = "white_check_mark"
+
+	// Subtracted from the student price when rating a dish
+	RATING_BONUS_VEGETARIAN = 0.3
+	RATING_BONUS_VEGAN      = 0.5
+
+	CANTEEN_URL_FORMAT = "http://speiseplan.studierendenwerk-hamburg.de/de/%s/2018/%d/"
+	CANTEEN_ID_DEFAULT = "580"
+
+	// The site serves today's plan at day 0 but uses 99 instead of 1 for tomorrow's plan
+	CANTEEN_DAY_TOMORROW = 99
 
 	CANTEEN_URL_MAFIASI_TODAY    = "https://mensa.mafiasi.de/api/canteens/{0}/today/"
 	CANTEEN_URL_MAFIASI_TOMORROW = "https://mensa.mafiasi.de/api/canteens/{0}/tomorrow/"
 )
 
-var REG_EXP_STATUS = regexp.MustCompile(`(?i)(?:^|\W)(alive|running|up)(?:$|\W)`)
+var REG_EXP_STATUS = regexp.MustCompile(`(?i)(?:^|\W)(alive|running|up|version)(?:$|\W)`)
 var REG_EXP_HELP = regexp.MustCompile(`(?i)(?:^|\W)(command(|s)|help)(?:$|\W)`)
-var REG_EXP_LEGEND = regexp.MustCompile(`(?i)(?:^|\W)(legend(|e)|zusatzstoff(|e)|nummer(|n))(?:$|\W)`)
+var REG_EXP_LEGEND = regexp.MustCompile(`(?i)(?:^|\W)(legend(|e))(?:$|\W)`)
+var REG_EXP_ADDITIVE_LIST = regexp.MustCompile(`(?i)(?:^|\W)(zusatzstoff(|e)|nummer(|n)|additives)(?:$|\W)`)
+var REG_EXP_ADDITIVE_LOOKUP = regexp.MustCompile(`(?i)(?:^|\W)(?:nummer(?:n)?|zusatzstoff(?:e)?|additives?)((?:[\s,]+\d+)+)`)
 
 var REG_EXP_TODAY = regexp.MustCompile(`(?i)(?:^|\W)(heute|today|hunger)(?:$|\W)`)
+var REG_EXP_MENU = regexp.MustCompile(`(?i)(?:^|\W)(speiseplan|men(ü|u)|essen)(?:$|\W)`)
 var REG_EXP_TOMORROW = regexp.MustCompile(`(?i)(?:^|\W)(morgen|tomorrow)(?:$|\W)`)
-
-var REG_EXP_ORDER = regexp.MustCompile(`^@\w+ order (?P<command>open|submit|list|close) ?(?P<content>.*)$`)
+var REG_EXP_DATE = regexp.MustCompile(`(?:^|\s)(\d{1,2})\.(\d{1,2})\.(\d{4})?(?:$|\s|[,!?])`)
+var REG_EXP_WEEKDAY = regexp.MustCompile(`(?i)(?:^|\W)(montag|dienstag|mittwoch|donnerstag|freitag|samstag|sonntag|monday|tuesday|wednesday|thursday|friday|saturday|sunday)(?:$|\W)`)
+var REG_EXP_VEGETARIAN = regexp.MustCompile(`(?i)(?:^|\W)(vegetari(sch|an)|veggie)(?:$|\W)`)
+var REG_EXP_VEGAN = regexp.MustCompile(`(?i)(?:^|\W)(vegan)(?:$|\W)`)
+var REG_EXP_WITHOUT = regexp.MustCompile(`(?i)(?:^|\W)(?:ohne|without)((?:[\s,]+\d+)+)`)
+var REG_EXP_CALORIES = regexp.MustCompile(`(?i)(?:^|\W)(kalorie(|n)|calorie(|s)|kcal)(?:$|\W)`)
+var REG_EXP_CANTEENS = regexp.MustCompile(`(?i)(?:^|\W)(mensen|canteens)(?:$|\W)`)
+var REG_EXP_SEARCH = regexp.MustCompile(`(?i)(?:^|\W)(?:suche|search)\s+(.+?)\s*$`)
+var REG_EXP_FAVORITE = regexp.MustCompile(`(?i)(?:^|\W)favorite?\s+(add|remove|list)\b\s*(.*)$`)
+var REG_EXP_FAVORITE_WEEK = regexp.MustCompile(`(?i)(?:^|\W)(?:lieblings(?:gerichte)?|favorites?)\s+(?:woche|week)(?:$|\W)`)
+var REG_EXP_FAVORITES = regexp.MustCompile(`(?i)(?:^|\W)(lieblingsgerichte|favorites)(?:$|\W)`)
+var REG_EXP_VEGAN_WEEK = regexp.MustCompile(`(?i)(?:^|\W)vegan(?:e|es)?\s+(?:woche|week)(?:$|\W)`)
+var REG_EXP_WEEK = regexp.MustCompile(`(?i)(?:^|\W)(woche|week)(?:$|\W)`)
+var REG_EXP_RANDOM = regexp.MustCompile(`(?i)(?:^|\W)(zufall|zufällig|random|egal)(?:$|\W)`)
+var REG_EXP_REFRESH = regexp.MustCompile(`(?i)(?:^|\W)(refresh|aktualisieren)(?:$|\W)`)
+
+// REG_EXP_ORDER tolerates any casing and any whitespace around the mention and the keywords
+var REG_EXP_ORDER = regexp.MustCompile(`(?i)^\s*(?:@[\w.-]+\s+)?order\s+(?P<command>open|poll|submit|withdraw|list|close)(?:\s+|$)(?P<content>.*)$`)
 
 //
 
+var REG_EXP_QUANTITY = regexp.MustCompile(`^(\d+)\s*[xX×]\s*(.+)$`)
+
+var REG_EXP_BROADCAST = regexp.MustCompile(`(?i)(?:^|\W)@(all|channel|here)(?:$|\W)`)
+
+var REG_EXP_ADDITIVES = regexp.MustCompile(`\(\s*\d+(?:\s*,\s*\d+)*\s*\)`)
+
+// REG_EXP_WHITESPACE also matches non-breaking spaces, which \s doesn't cover
+var REG_EXP_WHITESPACE = regexp.MustCompile(`[\s\p{Zs}]+`)
+
+var REG_EXP_KCAL = regexp.MustCompile(`(?i)(\d+)\s*kcal`)
+
+// Matches portion sizes like "200g", "200 g" or "0,5 l"
+var REG_EXP_PORTION = regexp.MustCompile(`(?i)(?:^|[\s(])(\d+(?:[.,]\d+)?)\s*(kg|g|ml|l)\b`)
+
+var REG_EXP_SUMMARY = regexp.MustCompile(`(?i)(?:^|\W)(übersicht|overview|summary)(?:$|\W)`)
+var REG_EXP_PRICES = regexp.MustCompile(`(?i)(?:^|\W)(preise|prices)(?:$|\W)`)
+var REG_EXP_AVERAGE = regexp.MustCompile(`(?i)(?:^|\W)(durchschnitt|average)(?:$|\W)`)
+var REG_EXP_NEXT_DAY = regexp.MustCompile(`(?i)(?:^|\W)(?:nächste[rn]?|next)\s+(vegan|vegetari(?:sch|an)|veggie|fleischlos|meat-free)`)
+var REG_EXP_CATEGORY = regexp.MustCompile(`(?i)(?:^|\W)(beilagen?|sides?|hauptgerichte?|mains?|desserts?|nachtisch|nachspeise|suppen?|soups?|salate?|salads?)(?:$|\W)`)
+var REG_EXP_UNDER = regexp.MustCompile(`(?i)(?:^|\W)(?:unter|under)\s+(\d\S*)`)
+var REG_EXP_NEW_TOMORROW = regexp.MustCompile(`(?i)(?:^|\W)(?:neu(?:e|es)?|new)\s+(?:morgen|tomorrow)(?:$|\W)`)
+var REG_EXP_CHEAP = regexp.MustCompile(`(?i)(?:^|\W)(günstig|billig|cheap(|est))(?:$|\W)`)
+var REG_EXP_VEGGIE_ONLY = regexp.MustCompile(`(?i)(?:^|\W)nur\s*veggie\s+(an|aus|on|off)(?:$|\W)`)
+var REG_EXP_SUBSCRIPTION = regexp.MustCompile(`(?i)(?:^|\W)(?:abo|subscription)\s+(an|aus|on|off)(?:$|\W)`)
+var REG_EXP_FEEDBACK = regexp.MustCompile(`(?is)(?:^|\W)feedback\s+(.+)$`)
+var REG_EXP_HOURS = regexp.MustCompile(`(?i)(?:^|\W)(öffnungszeit(|en)|geöffnet|opening\s+hours|hours)(?:$|\W)`)
+var REG_EXP_PROFILE = regexp.MustCompile(`(?i)(?:^|\W)profile?(?:$|\s+(.*)$)`)
+var REG_EXP_PROFILE_DELETE = regexp.MustCompile(`(?i)^(löschen|delete|clear|aus|off)$`)
+var REG_EXP_ALL = regexp.MustCompile(`(?i)(?:^|\s)(alle|all)(?:$|\W)`)
+var REG_EXP_RELOAD = regexp.MustCompile(`(?i)(?:^|\W)(reload)(?:$|\W)`)
+var REG_EXP_STATISTICS = regexp.MustCompile(`(?i)(?:^|\W)(statistik(|en)|statistics|stats)(?:$|\W)`)
+var REG_EXP_CHART = regexp.MustCompile(`(?i)(?:^|\W)(diagramm|grafik|chart)(?:$|\W)`)
+var REG_EXP_CSV = regexp.MustCompile(`(?i)(?:^|\W)(csv)(?:$|\W)`)
 var REG_EXP_THANKS = regexp.MustCompile(`(?i)(?:^|\W)(dank(|e)|thank(|s))(?:$|\W)`)
 
+// KEYWORD_COMMANDS maps the command names usable in the Keywords config to their regexes
+var KEYWORD_COMMANDS = map[string]**regexp.Regexp{
+	"status":     &REG_EXP_STATUS,
+	"help":       &REG_EXP_HELP,
+	"legend":     &REG_EXP_LEGEND,
+	"additives":  &REG_EXP_ADDITIVE_LIST,
+	"today":      &REG_EXP_TODAY,
+	"menu":       &REG_EXP_MENU,
+	"tomorrow":   &REG_EXP_TOMORROW,
+	"vegetarian": &REG_EXP_VEGETARIAN,
+	"vegan":      &REG_EXP_VEGAN,
+	"calories":   &REG_EXP_CALORIES,
+	"cheap":      &REG_EXP_CHEAP,
+	"canteens":   &REG_EXP_CANTEENS,
+	"random":     &REG_EXP_RANDOM,
+	"summary":    &REG_EXP_SUMMARY,
+	"prices":     &REG_EXP_PRICES,
+	"average":    &REG_EXP_AVERAGE,
+	"csv":        &REG_EXP_CSV,
+	"chart":      &REG_EXP_CHART,
+	"statistics": &REG_EXP_STATISTICS,
+	"hours":      &REG_EXP_HOURS,
+	"favorites":  &REG_EXP_FAVORITES,
+	"week":       &REG_EXP_WEEK,
+	"refresh":    &REG_EXP_REFRESH,
+	"thanks":     &REG_EXP_THANKS,
+}
+
+// keywordRegexp builds a command regex matching any of the keyword patterns as a separate word
+func keywordRegexp(keywords []string) (*regexp.Regexp, error) {
+	return regexp.Compile(`(?i)(?:^|\W)(` + strings.Join(keywords, "|") + `)(?:$|\W)`)
+}
+
+// KEYWORD_DEFAULTS are the built-in regexes of the commands in KEYWORD_COMMANDS
+var KEYWORD_DEFAULTS = func() map[string]*regexp.Regexp {
+	defaults := make(map[string]*regexp.Regexp, len(KEYWORD_COMMANDS))
+	for command, re := range KEYWORD_COMMANDS {
+		defaults[command] = *re
+	}
+	return defaults
+}()
+
+// applyKeywords replaces the regexes of the configured commands, leaving the others at their defaults
+func applyKeywords(keywords map[string][]string) error {
+	compiled, err := compileKeywords(keywords)
+	if err != nil {
+		return err
+	}
+
+	for command, re := range KEYWORD_DEFAULTS {
+		*KEYWORD_COMMANDS[command] = re
+	}
+	for command, re := range compiled {
+		*KEYWORD_COMMANDS[command] = re
+	}
+	return nil
+}
+
+// compileKeywords builds the regexes of the configured commands
+func compileKeywords(keywords map[string][]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp)
+	for command, words := range keywords {
+		if _, ok := KEYWORD_COMMANDS[command]; !ok {
+			return nil, fmt.Errorf("unknown command '%s'", command)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("no keywords for command '%s'", command)
+		}
+		re, err := keywordRegexp(words)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keywords for command '%s': %w", command, err)
+		}
+		compiled[command] = re
+	}
+	return compiled, nil
+}
+
 type config struct {
 	MattermostApiURL string
 	MattermostWsURL  string
@@ -53,18 +207,285 @@ type config struct {
 
 	ChannelNameDebug      string
 	ChannelNameProduction string
+	AllowedChannels       []string
 
 	Favorites []string
 
+	Language string
+
+	Emojis map[string]string
+
+	PriceLabels    []string
+	HideAdditives  bool
+	UseAttachments bool
+
+	MaxDishesPerMessage int
+
+	ShowRating   bool
+	RatingGreen  float64
+	RatingYellow float64
+
+	CanteenID      string
+	Canteens       []canteen
+	DefaultCanteen string
+
+	DishClass     string
+	PriceClass    string
+	CategoryClass string
+
 	UseMafiasiMensa  bool
 	CanteenIdMafiasi string
+
+	CacheTTL duration
+
+	FetchTimeout    duration
+	FetchAttempts   int
+	FetchRetryDelay duration
+
+	// UserAgent and RequestHeaders are sent with the canteen requests
+	UserAgent      string
+	RequestHeaders map[string]string
+
+	ScrapeAlertInterval duration
+
+	ApiTimeout duration
+
+	// PingInterval is how often the web socket is pinged, the connection counts as dead once
+	// nothing arrived on it for PingTimeout
+	PingInterval duration
+	PingTimeout  duration
+
+	OrderFile        string
+	FavoritesFile    string
+	SubscriptionFile string
+	ProfileFile      string
+	VeggieFile       string
+
+	PriceHistoryFile string
+	StatisticsFile   string
+
+	LogLevel string
+
+	ListenAddr string
+
+	SlashCommandToken     string
+	SlashCommandInChannel bool
+
+	RateLimit       int
+	RateLimitWindow duration
+
+	CommandPrefix string
+
+	Greeting        string
+	DisableGreeting bool
+
+	DisableReactionTriggers bool
+
+	Keywords map[string][]string
+
+	// Admins are the IDs of the users allowed to run admin commands like reload
+	Admins []string
+
+	OpeningHours map[string]string
+
+	FuzzyDistance int
+
+	DryRun         bool
+	DryRunPlanFile string
+
+	ScheduleTime   string
+	ScheduleThread bool
+
+	// QuietDates and QuietHours suppress the scheduled posts, e.g. during semester breaks
+	QuietDates []string
+	QuietHours string
+}
+
+// ENV_OVERRIDES maps environment variables to the settings they replace, so secrets don't have
+// to be stored in the config file
+var ENV_OVERRIDES = map[string]*string{
+	"MENSABOT_API_URL":             &CONFIG.MattermostApiURL,
+	"MENSABOT_WS_URL":              &CONFIG.MattermostWsURL,
+	"MENSABOT_AUTH_TOKEN":          &CONFIG.AuthToken,
+	"MENSABOT_SLASH_COMMAND_TOKEN": &CONFIG.SlashCommandToken,
+}
+
+// applyEnv overrides settings with the set environment variables of ENV_OVERRIDES
+func applyEnv() {
+	for name, setting := range ENV_OVERRIDES {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			slog.Debug("Overriding setting from environment", "variable", name)
+			*setting = value
+		}
+	}
+}
+
+// validate reports all missing required settings and invalid values at once
+func (c *config) validate() error {
+	var problems []string
+	required := func(name string, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, name+" is missing")
+		}
+	}
+
+	// A dry run never talks to Mattermost
+	if !c.DryRun {
+		required("MattermostApiURL", c.MattermostApiURL)
+		required("MattermostWsURL", c.MattermostWsURL)
+		required("AuthToken", c.AuthToken)
+		required("TeamName", c.TeamName)
+		required("ChannelNameDebug", c.ChannelNameDebug)
+		if c.ScheduleTime != "" {
+			required("ChannelNameProduction (needed for ScheduleTime)", c.ChannelNameProduction)
+		}
+	}
+	if c.SlashCommandToken != "" {
+		required("ListenAddr (needed for SlashCommandToken)", c.ListenAddr)
+	}
+	if !c.UseMafiasiMensa {
+		required("DishClass", c.DishClass)
+		required("PriceClass", c.PriceClass)
+	}
+
+	if c.ApiTimeout.Duration <= 0 {
+		problems = append(problems, "ApiTimeout must be positive")
+	}
+	if c.PingInterval.Duration > 0 && c.PingTimeout.Duration <= c.PingInterval.Duration {
+		problems = append(problems, "PingTimeout must be longer than PingInterval")
+	}
+	if _, ok := MESSAGES[c.Language]; !ok {
+		problems = append(problems, "Language '"+c.Language+"' is not supported")
+	}
+	if c.DefaultCanteen != "" {
+		found := false
+		for _, ct := range c.Canteens {
+			found = found || strings.EqualFold(ct.Name, c.DefaultCanteen)
+		}
+		if !found {
+			problems = append(problems, "DefaultCanteen '"+c.DefaultCanteen+"' is not one of the configured canteens")
+		}
+	}
+	if c.ScheduleTime != "" {
+		if _, err := parseSchedule(c.ScheduleTime); err != nil {
+			problems = append(problems, "ScheduleTime '"+c.ScheduleTime+"' is invalid: "+err.Error())
+		}
+	}
+	for _, dates := range c.QuietDates {
+		if _, _, err := parseQuietDates(dates, time.UTC); err != nil {
+			problems = append(problems, "QuietDates '"+dates+"' are invalid: "+err.Error())
+		}
+	}
+	if c.QuietHours != "" {
+		if _, _, err := parseQuietHours(c.QuietHours); err != nil {
+			problems = append(problems, "QuietHours are invalid: "+err.Error())
+		}
+	}
+	for day, span := range c.OpeningHours {
+		valid := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			valid = valid || day == strings.ToLower(d.String())
+		}
+		if !valid {
+			problems = append(problems, "OpeningHours contains the unknown day '"+day+"'")
+		} else if _, err := parseOpeningHours(span); err != nil {
+			problems = append(problems, "OpeningHours of "+day+" are invalid: "+err.Error())
+		}
+	}
+	for name := range c.Emojis {
+		if _, ok := EMOJI_DEFAULTS[name]; !ok {
+			problems = append(problems, "Emojis contains the unknown flag '"+name+"'")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// duration wraps time.Duration so it can be decoded from TOML strings like "15m"
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return
+}
+
+// canteen is a named canteen which can be selected by mentioning its name in a command
+type canteen struct {
+	Name string
+	ID   string
 }
 
-var CONFIG config
+var CONFIG = defaultConfig()
+
+// CONFIG_PATH is the file the config was read from, for reloading it
+var CONFIG_PATH string
+
+// LOG_LEVEL is the level of the default logger, it can be changed by reloading the config
+var LOG_LEVEL slog.LevelVar
+
+// defaultConfig returns the settings used for everything the config file leaves out
+func defaultConfig() config {
+	return config{
+		CacheTTL: duration{15 * time.Minute},
+
+		Language: LANGUAGE_DEFAULT,
+
+		PriceLabels: []string{"Studierende", "Bedienstete", "Gäste"},
+
+		RatingGreen:  2.5,
+		RatingYellow: 3.5,
+
+		DishClass:     "dish-description",
+		PriceClass:    "price",
+		CategoryClass: "category",
+
+		FetchTimeout:    duration{10 * time.Second},
+		FetchAttempts:   3,
+		FetchRetryDelay: duration{time.Second},
+
+		UserAgent: "mensabot/" + strings.TrimPrefix(VERSION, "v"),
+
+		ScrapeAlertInterval: duration{time.Hour},
+
+		ApiTimeout: duration{10 * time.Second},
+
+		PingInterval: duration{30 * time.Second},
+		PingTimeout:  duration{90 * time.Second},
+
+		RateLimitWindow: duration{time.Minute},
+
+		FuzzyDistance: 1,
+	}
+}
+
+// ERR_CONNECTION_LOST stops the bot when the web socket connection closes, so it can be restarted
+var ERR_CONNECTION_LOST = errors.New("web socket connection lost")
+
+// START_TIME is when the bot was started, for reporting its uptime
+var START_TIME time.Time
+
+// HTTP_CLIENT is used for all canteen requests, its overall timeout is taken from the config on startup
+var HTTP_CLIENT = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
 
 type dish struct {
 	name            string
+	category        string
 	prices          [3]string
+	priceValues     [3]float64
 	isVegetarian    bool
 	isVegan         bool
 	containsBeef    bool
@@ -72,6 +493,9 @@ type dish struct {
 	containsFish    bool
 	containsChicken bool
 	lactoseFree     bool
+	additives       []int
+	calories        int
+	portion         string
 }
 
 type mensabot struct {
@@ -81,11 +505,72 @@ type mensabot struct {
 	user *model.User
 	team *model.Team
 
-	channelDebug *model.Channel
+	// channelDebug gets the startup, shutdown and maintenance messages, channelProduction the
+	// scheduled posts and announcements. channelProduction is nil if none is configured
+	channelDebug      *model.Channel
+	channelProduction *model.Channel
+
+	// allowedChannels is nil if the bot may respond in any channel
+	allowedChannels map[string]bool
+
+	schedule *schedule
+	// dailyThread is the root post of today's thread in the production channel, only used by the scheduler
+	dailyThread dailyThread
+
+	server *http.Server
+
+	// ctx is cancelled on shutdown, wg tracks the goroutines which have to finish before exiting
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+}
+
+type planCacheEntry struct {
+	dishes    []dish
+	fetchedAt time.Time
+}
+
+type planCache struct {
+	sync.Mutex
+	entries map[string]planCacheEntry
+
+	// lastFetch is the time of the last successful fetch of any plan
+	lastFetch time.Time
+}
+
+var PLAN_CACHE = planCache{entries: make(map[string]planCacheEntry)}
+
+func (c *planCache) get(url string) ([]dish, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok || time.Since(entry.fetchedAt) >= CONFIG.CacheTTL.Duration {
+		return nil, false
+	}
+	return entry.dishes, true
+}
+
+func (c *planCache) put(url string, dishes []dish) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries[url] = planCacheEntry{dishes, time.Now()}
+	c.lastFetch = time.Now()
+}
+
+func (c *planCache) lastFetched() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.lastFetch
+}
+
+func (c *planCache) invalidate(url string) {
+	c.Lock()
+	defer c.Unlock()
 
-	orderUser   string
-	orderDetail string
-	orders      map[string]string
+	delete(c.entries, url)
 }
 
 type jsondish struct {
@@ -98,59 +583,222 @@ type jsondish struct {
 	Canteen    int    `json:"canteen"`
 }
 
-func (d dish) isFavorite() bool {
+// isFavorite reports whether the dish matches a configured favorite or a personal one of the user
+func (d dish) isFavorite(userID string) bool {
+	return d.matchesAny(CONFIG.Favorites) || d.matchesAny(FAVORITES.get(userID))
+}
+
+// matchesAny reports whether the dish name contains any of the lowercase terms
+func (d dish) matchesAny(terms []string) bool {
 	name := strings.ToLower(d.name)
-	for _, f := range CONFIG.Favorites {
-		if strings.Contains(name, f) {
+	for _, t := range terms {
+		if strings.Contains(name, t) {
 			return true
 		}
 	}
 	return false
 }
 
+// containsLactose reports whether the dish contains milk according to its additive codes
+func (d dish) containsLactose() bool {
+	return d.containsAnyAdditive([]int{ADDITIVE_MILK})
+}
+
+func (d dish) containsAnyAdditive(codes []int) bool {
+	for _, a := range d.additives {
+		for _, c := range codes {
+			if a == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// price returns the parsed price of the category, e.g. 0 for students, if the dish has one
+func (d dish) price(category int) (float64, bool) {
+	v := d.priceValues[category]
+	return v, v != PRICE_MISSING
+}
+
+// displayName returns the name to render, without the additive codes if HideAdditives is set
+func (d dish) displayName() string {
+	if !CONFIG.HideAdditives {
+		return d.name
+	}
+	// Only number lists are removed, trimNodeName then cleans up the spacing left behind
+	return trimNodeName(REG_EXP_ADDITIVES.ReplaceAllString(d.name, ""))
+}
+
 func (d dish) String() string {
+	return d.render("")
+}
+
+// score is the student price lowered by a bonus for vegetarian and vegan dishes, lower is better
+func (d dish) score() (float64, bool) {
+	price, ok := d.price(0)
+	if !ok {
+		return 0, false
+	}
+	if d.isVegan {
+		price -= RATING_BONUS_VEGAN
+	} else if d.isVegetarian {
+		price -= RATING_BONUS_VEGETARIAN
+	}
+	return price, true
+}
+
+// rating returns the traffic light for the dish's score or an empty string if it has no price
+func (d dish) rating() string {
+	score, ok := d.score()
+	switch {
+	case !ok:
+		return ""
+	case score <= CONFIG.RatingGreen:
+		return "🟢"
+	case score <= CONFIG.RatingYellow:
+		return "🟡"
+	default:
+		return "🔴"
+	}
+}
+
+// render formats the dish as a markdown table row, marking the favorites of the user
+func (d dish) render(userID string) string {
+	var buf bytes.Buffer
+	buf.WriteString("| ")
+	if CONFIG.ShowRating {
+		if r := d.rating(); r != "" {
+			buf.WriteString(r + " ")
+		}
+	}
+	buf.WriteString(d.displayName() + " |" + d.features(userID) + " |")
+
+	// Render one slot per price category so columns line up even if some prices are missing
+	// (mafiasi for example has no guest prices)
+	buf.WriteString(" " + strings.Join(d.priceSlots(), " // ") + " |")
+
+	return buf.String()
+}
+
+// EMOJI_FLAGS are the dish flags shown as emojis, in the order of the legend
+var EMOJI_FLAGS = []string{"favorite", "vegan", "vegetarian", "beef", "pork", "fish", "chicken", "lactosefree", "milk"}
+
+// EMOJI_DEFAULTS are the emojis of the flags unless overridden by the Emojis config
+var EMOJI_DEFAULTS = map[string]string{
+	"favorite":    "heart_eyes",
+	"vegan":       "sunflower",
+	"vegetarian":  "carrot",
+	"beef":        "cow2",
+	"pork":        "pig2",
+	"fish":        "fish",
+	"chicken":     "rooster",
+	"lactosefree": "milk_glass",
+	"milk":        "cheese",
+}
+
+// emoji returns the markdown of the emoji configured for flag, e.g. ":sunflower:"
+func emoji(flag string) string {
+	name, ok := CONFIG.Emojis[flag]
+	if !ok {
+		name = EMOJI_DEFAULTS[flag]
+	}
+	return ":" + strings.Trim(name, ":") + ":"
+}
+
+// features renders the emojis of the dish's dietary flags, its calories and its portion size, each
+// with a leading space
+func (d dish) features(userID string) string {
 	var buf bytes.Buffer
-	buf.WriteString("| " + d.name + " |")
-	if d.isFavorite() {
-		buf.WriteString(" :heart_eyes:")
+	if d.isFavorite(userID) {
+		buf.WriteString(" " + emoji("favorite"))
 	}
 	if d.isVegan {
-		buf.WriteString(" :sunflower:")
+		buf.WriteString(" " + emoji("vegan"))
 	} else if d.isVegetarian {
-		buf.WriteString(" :carrot:")
+		buf.WriteString(" " + emoji("vegetarian"))
 	}
 	if d.containsBeef {
-		buf.WriteString(" :cow2:")
+		buf.WriteString(" " + emoji("beef"))
 	}
 	if d.containsPork {
-		buf.WriteString(" :pig2:")
+		buf.WriteString(" " + emoji("pork"))
 	}
 	if d.containsFish {
-		buf.WriteString(" :fish:")
+		buf.WriteString(" " + emoji("fish"))
 	}
 	if d.containsChicken {
-		buf.WriteString(" :rooster:")
+		buf.WriteString(" " + emoji("chicken"))
 	}
 	if d.lactoseFree {
-		buf.WriteString(" :milk_glass:")
+		// Only shown if the canteen explicitly tagged the dish
+		buf.WriteString(" " + emoji("lactosefree"))
+	} else if d.containsLactose() {
+		buf.WriteString(" " + emoji("milk"))
+	}
+	if d.calories > 0 {
+		buf.WriteString(fmt.Sprintf(" %d kcal", d.calories))
 	}
-	buf.WriteString(" |")
+	if d.portion != "" {
+		buf.WriteString(" " + d.portion)
+	}
+	return buf.String()
+}
 
-	if len(d.prices[2]) != 0 {
-		buf.WriteString(fmt.Sprintf(" %s // %s // %s |", d.prices[0], d.prices[1], d.prices[2]))
-	} else {
-		buf.WriteString(fmt.Sprintf(" %s // %s |", d.prices[0], d.prices[1])) // mafiasi only has 2 prices
+// priceSlots returns one price per price label, "-" if the dish has none in that category
+func (d dish) priceSlots() []string {
+	prices := make([]string, len(CONFIG.PriceLabels))
+	for i := range prices {
+		prices[i] = "-"
+		if i < len(d.prices) && d.prices[i] != "" {
+			prices[i] = d.prices[i]
+		}
 	}
+	return prices
+}
 
-	return buf.String()
+// attachment renders the dish as a message attachment with a field per price category
+func (d dish) attachment(userID string) *model.SlackAttachment {
+	title := d.displayName()
+	if CONFIG.ShowRating {
+		if r := d.rating(); r != "" {
+			title = r + " " + title
+		}
+	}
+
+	var fields []*model.SlackAttachmentField
+	if features := strings.TrimSpace(d.features(userID)); features != "" {
+		fields = append(fields, &model.SlackAttachmentField{Title: tr("attachment.features"), Value: features})
+	}
+	for i, price := range d.priceSlots() {
+		fields = append(fields, &model.SlackAttachmentField{Title: CONFIG.PriceLabels[i], Value: price, Short: true})
+	}
+
+	return &model.SlackAttachment{
+		Fallback: title + " " + strings.Join(d.priceSlots(), " // "),
+		Title:    title,
+		Fields:   fields,
+	}
+}
+
+func filterDishes(dishes []dish, pred func(dish) bool) (filtered []dish) {
+	for _, d := range dishes {
+		if pred(d) {
+			filtered = append(filtered, d)
+		}
+	}
+	return
 }
 
+// trimNodeName decodes the HTML entities left in name, like the ones the site escapes twice,
+// collapses all whitespace including non-breaking spaces to single spaces and drops the spaces
+// the site puts inside parentheses and before commas
 func trimNodeName(name string) (trimmed string) {
-	trimmed = strings.Trim(name, " \t\n")
+	trimmed = html.UnescapeString(name)
+	trimmed = strings.TrimSpace(REG_EXP_WHITESPACE.ReplaceAllString(trimmed, " "))
 	trimmed = strings.Replace(trimmed, "( ", "(", -1)
 	trimmed = strings.Replace(trimmed, " )", ")", -1)
 	trimmed = strings.Replace(trimmed, " ,", ",", -1)
-	trimmed = strings.Replace(trimmed, "  ", " ", -1)
 
 	return
 }
@@ -164,6 +812,55 @@ func replaceNonNumeric(replace string) (replaced string) {
 	return
 }
 
+// parseAdditives collects the additive codes from all parenthesized number lists like "(2, 14)" in name
+func parseAdditives(name string) (additives []int) {
+	for _, group := range REG_EXP_ADDITIVES.FindAllString(name, -1) {
+		for _, code := range strings.Split(replaceNonNumeric(group), ",") {
+			if n, err := strconv.Atoi(code); err == nil {
+				additives = append(additives, n)
+			}
+		}
+	}
+	return
+}
+
+// parseCalories returns the first "<n> kcal" figure in text or 0 if there is none
+func parseCalories(text string) int {
+	match := REG_EXP_KCAL.FindStringSubmatch(text)
+	if match == nil {
+		return 0
+	}
+	calories, _ := strconv.Atoi(match[1])
+	return calories
+}
+
+// parsePortion returns the first portion size like "200 g" in text or "" if there is none
+func parsePortion(text string) string {
+	match := REG_EXP_PORTION.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return match[1] + " " + strings.ToLower(match[2])
+}
+
+// parsePrice parses prices like "2,50 €" or "2.50" into euros
+func parsePrice(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.Replace(strings.TrimSuffix(strings.TrimSpace(s), "€"), ",", ".", 1))
+	price, err := strconv.ParseFloat(s, 64)
+	return price, err == nil && price >= 0
+}
+
+// parsePrices parses the price of each category, PRICE_MISSING marking blank or unparsable ones
+func parsePrices(prices [3]string) (values [3]float64) {
+	for i, p := range prices {
+		values[i] = PRICE_MISSING
+		if v, ok := parsePrice(p); ok {
+			values[i] = v
+		}
+	}
+	return
+}
+
 func dishFromNode(node *html.Node) dish {
 	name := trimNodeName(scrape.Text(node))
 
@@ -176,11 +873,11 @@ func dishFromNode(node *html.Node) dish {
 	var containsChicken bool
 	var lactoseFree bool
 
-	priceNodes := scrape.FindAll(node.Parent, scrape.ByClass("price"))
+	priceNodes := scrape.FindAll(node.Parent, scrape.ByClass(CONFIG.PriceClass))
 	imgNodes := scrape.FindAll(node, scrape.ByTag(atom.Img))
 
 	for i, price := range priceNodes {
-		prices[i] = strings.Replace(scrape.Text(price), "\xc2\xa0", "", -1)
+		prices[i] = trimNodeName(scrape.Text(price))
 	}
 
 	for _, img := range imgNodes {
@@ -202,61 +899,315 @@ func dishFromNode(node *html.Node) dish {
 		}
 	}
 
-	return dish{name, prices, isVegetarian || isVegan, isVegan, containsBeef, containsPork, containsFish, containsChicken, lactoseFree}
+	return dish{
+		name:            name,
+		category:        dishCategory(node),
+		prices:          prices,
+		priceValues:     parsePrices(prices),
+		isVegetarian:    isVegetarian || isVegan,
+		isVegan:         isVegan,
+		containsBeef:    containsBeef,
+		containsPork:    containsPork,
+		containsFish:    containsFish,
+		containsChicken: containsChicken,
+		lactoseFree:     lactoseFree,
+		additives:       parseAdditives(name),
+		calories:        parseCalories(scrape.Text(node.Parent)),
+		portion:         parsePortion(scrape.Text(node.Parent)),
+	}
 }
 
-func getCanteenPlan(url string) (dishes []dish) {
-	resp, err := http.Get(url)
-	if err != nil {
-		panic(err)
-	}
-	root, err := html.Parse(resp.Body)
-	if err != nil {
-		panic(err)
+// dishCategory returns the name of the counter the dish is listed under, i.e. the text of the
+// closest category node preceding it, or "" if the page has none or CategoryClass is empty
+func dishCategory(node *html.Node) string {
+	if CONFIG.CategoryClass == "" {
+		return ""
 	}
-
-	dishNodes := scrape.FindAll(root, scrape.ByClass("dish-description"))
-
-	for _, dn := range dishNodes {
-		dishes = append(dishes, dishFromNode(dn))
+	for n := node; n != nil; n = n.Parent {
+		for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+			if s.Type != html.ElementNode {
+				continue
+			}
+			if categories := scrape.FindAll(s, scrape.ByClass(CONFIG.CategoryClass)); len(categories) > 0 {
+				return trimNodeName(scrape.Text(categories[len(categories)-1]))
+			}
+		}
 	}
+	return ""
+}
 
-	return
+var WEEKDAYS = map[string]time.Weekday{
+	"sonntag": time.Sunday, "sunday": time.Sunday,
+	"montag": time.Monday, "monday": time.Monday,
+	"dienstag": time.Tuesday, "tuesday": time.Tuesday,
+	"mittwoch": time.Wednesday, "wednesday": time.Wednesday,
+	"donnerstag": time.Thursday, "thursday": time.Thursday,
+	"freitag": time.Friday, "friday": time.Friday,
+	"samstag": time.Saturday, "saturday": time.Saturday,
 }
 
-func getCanteenPlanMafiasi(url string, idString string) (dishes []dish) {
+// weekdayOffset returns the number of days until the next occurrence of day, today being 0
+func weekdayOffset(day time.Weekday) int {
+	return (int(day) - int(time.Now().Weekday()) + 7) % 7
+}
 
-	url = strings.Replace(url, "{0}", idString, 1)
+// defaultCanteen returns the configured default canteen or an unnamed one using the plain canteen ID config
+func defaultCanteen() canteen {
+	for _, c := range CONFIG.Canteens {
+		if strings.EqualFold(c.Name, CONFIG.DefaultCanteen) {
+			return c
+		}
+	}
 
-	res, err := http.Get(url)
-	if err != nil {
-		fmt.Println(err)
+	if CONFIG.UseMafiasiMensa {
+		return canteen{ID: CONFIG.CanteenIdMafiasi}
 	}
-	defer res.Body.Close()
+	if CONFIG.CanteenID != "" {
+		return canteen{ID: CONFIG.CanteenID}
+	}
+	return canteen{ID: CANTEEN_ID_DEFAULT}
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		panic(err)
+// selectCanteen returns the configured canteen named in msg, falling back to the default canteen
+func selectCanteen(msg string) canteen {
+	msg = strings.ToLower(msg)
+	for _, c := range CONFIG.Canteens {
+		for _, word := range strings.Fields(strings.ToLower(c.Name)) {
+			// Nearly every canteen is called "Mensa ...", so that part does not identify one
+			if word != "mensa" && strings.Contains(msg, word) {
+				return c
+			}
+		}
 	}
-	var data []jsondish
+	return defaultCanteen()
+}
 
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		panic(err)
+// suffix returns the canteen name for use in reply headers
+func (c canteen) suffix() string {
+	if c.Name == "" {
+		return ""
 	}
+	return " (" + c.Name + ")"
+}
 
-	for _, current := range data {
-		prices := [3]string{current.Price, current.PriceStaff, ""}
-		dishes = append(dishes, dish{current.Name, prices, current.Vegetarian, current.Vegan, false, false, false, false, false})
+func canteenURLForOffset(canteenID string, offset int) string {
+	if offset == 1 {
+		offset = CANTEEN_DAY_TOMORROW
 	}
-	return dishes
+	return fmt.Sprintf(CANTEEN_URL_FORMAT, canteenID, offset)
 }
 
-func newMensaBotFromConfig(cfg *config) (bot *mensabot) {
-	println("[newMensaBotFromConfig] Connecting to " + cfg.MattermostApiURL)
-	client := model.NewAPIv4Client(cfg.MattermostApiURL)
+// statusError is returned for responses without a 2xx status code
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
 
-	bot = &mensabot{client: client}
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s responded with %s", e.url, e.status)
+}
+
+// newFetchRequest builds the GET request for url with the configured user agent and headers
+func newFetchRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range CONFIG.RequestHeaders {
+		req.Header.Set(name, value)
+	}
+	if CONFIG.UserAgent != "" {
+		req.Header.Set("User-Agent", CONFIG.UserAgent)
+	}
+	return req, nil
+}
+
+// fetch gets url, retrying with exponential backoff on network errors and 5xx responses
+func fetch(url string) (resp *http.Response, err error) {
+	req, err := newFetchRequest(url)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := CONFIG.FetchRetryDelay.Duration
+	for attempt := 1; ; attempt++ {
+		resp, err = HTTP_CLIENT.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+			// Client errors won't go away by asking again
+			if resp.StatusCode < 500 {
+				return nil, err
+			}
+		}
+		if attempt >= CONFIG.FetchAttempts {
+			return nil, err
+		}
+
+		slog.Warn("Fetch failed, retrying", "url", url, "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func getCanteenPlan(url string) (dishes []dish, err error) {
+	if CONFIG.DryRun && CONFIG.DryRunPlanFile != "" {
+		return readCanteenPlanFile(CONFIG.DryRunPlanFile)
+	}
+	if cached, ok := PLAN_CACHE.get(url); ok {
+		return cached, nil
+	}
+
+	resp, err := fetch(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching canteen plan: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dishes, err = parseCanteenPlan(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	dishes = dedupDishes(dishes)
+
+	PLAN_CACHE.put(url, dishes)
+	return
+}
+
+// readCanteenPlanFile parses a locally saved plan page
+func readCanteenPlanFile(path string) ([]dish, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading canteen plan: %w", err)
+	}
+	defer f.Close()
+
+	dishes, err := parseCanteenPlan(f)
+	if err != nil {
+		return nil, err
+	}
+	return dedupDishes(dishes), nil
+}
+
+// dedupDishes drops dishes with the same name and prices as an earlier one, as the site
+// sometimes lists a dish once per serving line
+func dedupDishes(dishes []dish) (unique []dish) {
+	seen := make(map[string]bool)
+	for _, d := range dishes {
+		key := d.name + "|" + strings.Join(d.prices[:], "|")
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, d)
+		}
+	}
+	return
+}
+
+// parseCanteenPlan extracts the dishes from a Studierendenwerk plan page
+func parseCanteenPlan(r io.Reader) (dishes []dish, err error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing canteen plan: %w", err)
+	}
+
+	dishNodes := scrape.FindAll(root, scrape.ByClass(CONFIG.DishClass))
+	if len(dishNodes) == 0 {
+		// Closed days look the same, but an empty plan on a weekday usually means the site changed
+		slog.Warn("Canteen plan contains no dishes, check DishClass if the site layout changed", "class", CONFIG.DishClass)
+	}
+
+	for _, dn := range dishNodes {
+		dishes = append(dishes, dishFromNode(dn))
+	}
+
+	return
+}
+
+func getCanteenPlanMafiasi(url string, idString string) (dishes []dish, err error) {
+
+	url = strings.Replace(url, "{0}", idString, 1)
+	if cached, ok := PLAN_CACHE.get(url); ok {
+		return cached, nil
+	}
+
+	res, err := fetch(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching mafiasi canteen plan: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading mafiasi canteen plan: %w", err)
+	}
+	var data []jsondish
+
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding mafiasi canteen plan: %w", err)
+	}
+
+	for _, current := range data {
+		prices := [3]string{current.Price, current.PriceStaff, ""}
+		dishes = append(dishes, dish{
+			name:         current.Name,
+			prices:       prices,
+			priceValues:  parsePrices(prices),
+			isVegetarian: current.Vegetarian,
+			isVegan:      current.Vegan,
+			additives:    parseAdditives(current.Name),
+		})
+	}
+
+	PLAN_CACHE.put(url, dishes)
+	return dishes, nil
+}
+
+// getPlan fetches the plan of the canteen for the day offset (0 being today) from the configured source,
+// bypassing the cache if refresh is set
+func getPlan(canteenID string, offset int, refresh bool) (dishes []dish, err error) {
+	if CONFIG.UseMafiasiMensa {
+		var url string
+		switch offset {
+		case 0:
+			url = CANTEEN_URL_MAFIASI_TODAY
+		case 1:
+			url = CANTEEN_URL_MAFIASI_TOMORROW
+		default:
+			return nil, fmt.Errorf("mafiasi only provides plans for today and tomorrow, not for day offset %d", offset)
+		}
+		if refresh {
+			PLAN_CACHE.invalidate(strings.Replace(url, "{0}", canteenID, 1))
+		}
+		dishes, err = getCanteenPlanMafiasi(url, canteenID)
+	} else {
+		url := canteenURLForOffset(canteenID, offset)
+		if refresh {
+			PLAN_CACHE.invalidate(url)
+		}
+		dishes, err = getCanteenPlan(url)
+	}
+
+	HEALTH.recordFetch(err)
+	SCRAPE_ALERTS.check(canteenID, offset, dishes, err)
+	if err == nil && offset == 0 {
+		PRICES.record(canteenID, dishes)
+		STATISTICS.record(canteenID, dishes)
+	}
+	return dishes, err
+}
+
+func newMensaBotFromConfig(cfg *config) (bot *mensabot) {
+	slog.Info("Connecting to Mattermost", "url", cfg.MattermostApiURL)
+	client := model.NewAPIv4Client(cfg.MattermostApiURL)
+	client.HttpClient.Timeout = cfg.ApiTimeout.Duration
+
+	bot = &mensabot{client: client}
+	bot.ctx, bot.cancel = context.WithCancelCause(context.Background())
 
 	bot.setupGracefulShutdown()
 	bot.ensureServerIsRunning()
@@ -264,76 +1215,226 @@ func newMensaBotFromConfig(cfg *config) (bot *mensabot) {
 	bot.setTeam(cfg.TeamName)
 
 	if wsClient, err := model.NewWebSocketClient4(cfg.MattermostWsURL, bot.client.AuthToken); err != nil {
-		println("[newMensaBotFromConfig] Failed to connect to the web socket")
-		printError(err)
+		logAppError("Failed to connect to the web socket", err, "url", cfg.MattermostWsURL)
 		panic(err)
 	} else {
 		bot.wsClient = wsClient
 	}
 
 	bot.channelDebug = bot.getChannel(cfg.ChannelNameDebug)
+	if cfg.ChannelNameProduction != "" {
+		bot.channelProduction = bot.getChannel(cfg.ChannelNameProduction)
+	}
+	SCRAPE_ALERTS.send = func(msg string) { bot.sendMessage(msg, bot.channelDebug.Id, "") }
+	bot.allowedChannels = bot.lookupAllowedChannels(cfg.AllowedChannels)
+	ORDERS.load(cfg.OrderFile)
+	FAVORITES.load(cfg.FavoritesFile)
+	SUBSCRIBERS.load(cfg.SubscriptionFile)
+	PROFILES.load(cfg.ProfileFile)
+	VEGGIE_CHANNELS.load(cfg.VeggieFile)
+	PRICES.load(cfg.PriceHistoryFile)
+	STATISTICS.load(cfg.StatisticsFile)
+
+	if cfg.ScheduleTime != "" {
+		schedule, err := parseSchedule(cfg.ScheduleTime)
+		if err != nil {
+			slog.Error("Invalid schedule time", "time", cfg.ScheduleTime, "error", err)
+			panic(err)
+		}
+		bot.schedule = schedule
+	}
+
+	if cfg.ListenAddr != "" {
+		bot.startServer(cfg.ListenAddr)
+	}
 
 	return
 }
 
+// setupGracefulShutdown cancels the bot's context on SIGINT or SIGTERM
 func (bot *mensabot) setupGracefulShutdown() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		for _ = range c {
-			if bot.wsClient != nil {
-				bot.wsClient.Close()
-			}
-
-			bot.sendMessage("_["+CONFIG.DisplayName+"] has **stopped** running_", bot.channelDebug.Id, "")
-			os.Exit(0)
-		}
+		sig := <-c
+		slog.Info("Received signal, shutting down", "signal", sig)
+		bot.cancel(nil)
 	}()
 }
 
+// shutdown stops listening and serving, waits for the running goroutines and reports the stop
+func (bot *mensabot) shutdown() {
+	if bot.wsClient != nil {
+		bot.wsClient.Close()
+	}
+	bot.stopServer()
+	bot.wg.Wait()
+
+	bot.sendMessage(tr("bot.stopped", CONFIG.DisplayName), bot.channelDebug.Id, "")
+}
+
 func (bot *mensabot) ensureServerIsRunning() {
 	if props, resp := bot.client.GetOldClientConfig(""); resp.Error != nil {
-		println("There was a problem pinging the Mattermost server.  Are you sure it's running?")
-		printError(resp.Error)
+		logAppError("There was a problem pinging the Mattermost server. Are you sure it's running?", resp.Error)
 		os.Exit(1)
 	} else {
-		println("[bot::ensureServerIsRunning] Server detected and is running version " + props["Version"])
+		slog.Info("Server detected and is running", "version", props["Version"])
+	}
+}
+
+// withTimeout runs the Mattermost API call, giving up on it after ApiTimeout so a hung request
+// can't stall the handling of the following events. The call itself is bounded by the timeout of
+// the client's HTTP client, so an abandoned call doesn't linger
+func withTimeout(name string, call func() *model.Response) *model.Response {
+	ctx, cancel := context.WithTimeout(context.Background(), CONFIG.ApiTimeout.Duration)
+	defer cancel()
+
+	done := make(chan *model.Response, 1)
+	go func() { done <- call() }()
+
+	select {
+	case resp := <-done:
+		return resp
+	case <-ctx.Done():
+		slog.Warn("Mattermost API call timed out", "call", name, "timeout", CONFIG.ApiTimeout.Duration)
+		return &model.Response{
+			StatusCode: http.StatusGatewayTimeout,
+			Error:      model.NewAppError(name, "mensabot.api.timeout", nil, ctx.Err().Error(), http.StatusGatewayTimeout),
+		}
 	}
 }
 
 func (bot *mensabot) loginAsBotUser(token string) {
 	bot.client.SetToken(token)
-	if user, resp := bot.client.GetMe(""); resp.Error != nil {
-		println("There was a problem logging into the Mattermost server.")
-		printError(resp.Error)
+	var user *model.User
+	if resp := withTimeout("GetMe", func() (resp *model.Response) {
+		user, resp = bot.client.GetMe("")
+		return
+	}); resp.Error != nil {
+		logAppError("There was a problem logging into the Mattermost server", resp.Error)
 		panic(resp.Error)
 	} else {
-		println("[bot::loginAsBotUser] Logged in as user '" + user.GetFullName() + "': " + user.Id)
+		slog.Info("Logged in", "user", user.GetFullName(), "user_id", user.Id)
 		bot.user = user
 	}
 }
 
+// RESOLVE_RETRY_DELAY is the delay before resolving the team or a channel again, it doubles after
+// each failure up to RESOLVE_RETRY_MAX_DELAY
+const RESOLVE_RETRY_DELAY = 5 * time.Second
+const RESOLVE_RETRY_MAX_DELAY = 5 * time.Minute
+
+// retryResolve calls resolve until it succeeds, so a renamed team or channel is reported in the
+// log instead of making the bot crash-loop. The bot exits if it's stopped in the meantime
+func (bot *mensabot) retryResolve(msg string, resolve func() *model.AppError, args ...any) {
+	delay := RESOLVE_RETRY_DELAY
+	for attempt := 1; ; attempt++ {
+		err := resolve()
+		if err == nil {
+			return
+		}
+		logAppError(msg, err, append(args, "attempt", attempt, "retry_in", delay)...)
+
+		select {
+		case <-bot.ctx.Done():
+			slog.Info("Stopped before startup finished")
+			os.Exit(0)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, RESOLVE_RETRY_MAX_DELAY)
+	}
+}
+
 func (bot *mensabot) setTeam(teamName string) {
-	if team, resp := bot.client.GetTeamByName(teamName, ""); resp.Error != nil {
-		println("We failed to get the initial load")
-		println("or we do not appear to be a member of the team '" + teamName + "'")
-		printError(resp.Error)
-		panic(resp.Error)
-	} else {
-		println("[bot::setTeam] Got team with name '" + teamName + "`: " + team.Id)
+	bot.retryResolve("Failed to get the team, check TeamName and that the bot is a member of the team", func() *model.AppError {
+		team, resp := bot.client.GetTeamByName(teamName, "")
+		if resp.Error != nil {
+			return resp.Error
+		}
 		bot.team = team
+		return nil
+	}, "team", teamName)
+	slog.Info("Got team", "team", teamName, "team_id", bot.team.Id)
+}
+
+// lookupChannel resolves the channel by name or, if there is no channel of that name, by ID
+func (bot *mensabot) lookupChannel(nameOrID string) (*model.Channel, *model.AppError) {
+	var channel *model.Channel
+	resp := withTimeout("GetChannelByName", func() (resp *model.Response) {
+		channel, resp = bot.client.GetChannelByName(nameOrID, bot.team.Id, "")
+		return
+	})
+	if resp.Error != nil && model.IsValidId(nameOrID) {
+		resp = withTimeout("GetChannel", func() (resp *model.Response) {
+			channel, resp = bot.client.GetChannel(nameOrID, "")
+			return
+		})
 	}
+	return channel, resp.Error
 }
 
-func (bot *mensabot) getChannel(channelName string) *model.Channel {
-	rChan, resp := bot.client.GetChannelByName(channelName, bot.team.Id, "")
-	if resp.Error != nil {
-		println("We failed to get the channel: " + channelName)
-		printError(resp.Error)
-		panic(resp.Error)
+// getChannel resolves the channel by name or ID, retrying until it exists
+func (bot *mensabot) getChannel(nameOrID string) (channel *model.Channel) {
+	bot.retryResolve("Failed to get the channel, update its name in the config if it was renamed or use its ID", func() (err *model.AppError) {
+		channel, err = bot.lookupChannel(nameOrID)
+		return
+	}, "channel", nameOrID)
+	slog.Info("Got channel", "channel", nameOrID, "channel_id", channel.Id)
+	return
+}
+
+// lookupAllowedChannels looks up the IDs of the allowed channels, skipping unknown ones. It
+// returns nil if no channels are configured, i.e. all channels are allowed
+func (bot *mensabot) lookupAllowedChannels(channelNames []string) map[string]bool {
+	if len(channelNames) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range channelNames {
+		var channel *model.Channel
+		resp := withTimeout("GetChannelByName", func() (resp *model.Response) {
+			channel, resp = bot.client.GetChannelByName(name, bot.team.Id, "")
+			return
+		})
+		if resp.Error != nil {
+			slog.Warn("Ignoring allowed channel which could not be resolved", "channel", name, "error", resp.Error.Message)
+			continue
+		}
+		allowed[channel.Id] = true
+	}
+	slog.Info("Restricted to allowed channels", "channels", len(allowed))
+	return allowed
+}
+
+// isAllowedChannel reports whether the bot may respond in the channel, the debug and production
+// channels always being allowed
+func (bot *mensabot) isAllowedChannel(channelID string) bool {
+	if bot.channelProduction != nil && channelID == bot.channelProduction.Id {
+		return true
+	}
+	return bot.allowedChannels == nil || bot.allowedChannels[channelID] || channelID == bot.channelDebug.Id
+}
+
+// getUser looks up a user, in dry-run mode or if the lookup fails the user is made up from the ID
+// so callers can always render a name
+func (bot *mensabot) getUser(userID string) *model.User {
+	if CONFIG.DryRun {
+		return &model.User{Id: userID, Username: userID}
 	}
-	println("[bot::getChannel] Got channel with name '" + channelName + "': " + rChan.Id)
-	return rChan
+
+	var user *model.User
+	resp := withTimeout("GetUser", func() (resp *model.Response) {
+		user, resp = bot.client.GetUser(userID, "")
+		return
+	})
+	if resp.Error != nil || user == nil {
+		if resp.Error != nil {
+			logAppError("Failed to get user, showing the user ID instead", resp.Error, "user_id", userID)
+		}
+		return &model.User{Id: userID, Username: userID}
+	}
+	return user
 }
 
 func (bot *mensabot) sendMessage(msg string, channelID string, replyToID string) {
@@ -342,52 +1443,207 @@ func (bot *mensabot) sendMessage(msg string, channelID string, replyToID string)
 	post.Message = msg
 	post.RootId = replyToID
 
-	if _, resp := bot.client.CreatePost(post); resp.Error != nil {
-		println("We failed to send a message to channel: " + channelID)
-		printError(resp.Error)
+	bot.createPost(post)
+}
+
+// createPost sends the post and returns the created one, or nil if that failed
+func (bot *mensabot) createPost(post *model.Post) *model.Post {
+	if CONFIG.DryRun {
+		printDryRunPost(post)
+		return post
+	}
+
+	var created *model.Post
+	if resp := withTimeout("CreatePost", func() (resp *model.Response) {
+		created, resp = bot.client.CreatePost(post)
+		return
+	}); resp.Error != nil {
+		logAppError("We failed to send a message", resp.Error, "channel_id", post.ChannelId)
+		return nil
+	}
+	return created
+}
+
+func (bot *mensabot) addReaction(postID string, emoji string) {
+	if CONFIG.DryRun {
+		return
+	}
+
+	reaction := &model.Reaction{UserId: bot.user.Id, PostId: postID, EmojiName: emoji}
+	if resp := withTimeout("SaveReaction", func() (resp *model.Response) {
+		_, resp = bot.client.SaveReaction(reaction)
+		return
+	}); resp.Error != nil {
+		logAppError("We failed to add a reaction", resp.Error, "post_id", postID, "emoji", emoji)
+	}
+}
+
+func (bot *mensabot) removeReaction(postID string, emoji string) {
+	if CONFIG.DryRun {
+		return
+	}
+
+	reaction := &model.Reaction{UserId: bot.user.Id, PostId: postID, EmojiName: emoji}
+	if resp := withTimeout("DeleteReaction", func() (resp *model.Response) {
+		_, resp = bot.client.DeleteReaction(reaction)
+		return
+	}); resp.Error != nil {
+		logAppError("We failed to remove a reaction", resp.Error, "post_id", postID, "emoji", emoji)
 	}
 }
 
 func (bot *mensabot) startListening() {
-	bot.sendMessage("_["+CONFIG.DisplayName+"] has **started** running_", bot.channelDebug.Id, "")
+	bot.sendMessage(tr("bot.started", CONFIG.DisplayName), bot.channelDebug.Id, "")
 	bot.wsClient.Listen()
+	HEALTH.connected.Store(true)
+
+	if bot.schedule != nil {
+		bot.wg.Add(1)
+		go func() {
+			defer bot.wg.Done()
+			bot.runSchedule()
+		}()
+	}
+
+	// Proxies may drop idle connections without closing them, so the bot pings the server and
+	// gives up on the connection once neither events nor ping responses arrive anymore
+	lastActivity := time.Now()
+	var ping <-chan time.Time
+	if CONFIG.PingInterval.Duration > 0 {
+		ticker := time.NewTicker(CONFIG.PingInterval.Duration)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	responses := bot.wsClient.ResponseChannel
 
 	for {
 		select {
-		case event := <-bot.wsClient.EventChannel:
+		case <-bot.ctx.Done():
+			return
+		case <-ping:
+			if silence := time.Since(lastActivity); silence > CONFIG.PingTimeout.Duration {
+				HEALTH.connected.Store(false)
+				slog.Error("Web socket connection timed out", "silence", silence)
+				bot.cancel(ERR_CONNECTION_LOST)
+				return
+			}
+			bot.wsClient.SendMessage("ping", nil)
+		case _, ok := <-responses:
+			if !ok {
+				// The closed event channel reports the lost connection
+				responses = nil
+				continue
+			}
+			lastActivity = time.Now()
+		case event, ok := <-bot.wsClient.EventChannel:
+			if !ok {
+				HEALTH.connected.Store(false)
+				if bot.wsClient.ListenError != nil {
+					logAppError("Web socket connection closed", bot.wsClient.ListenError)
+				} else {
+					slog.Error("Web socket connection closed")
+				}
+				bot.cancel(ERR_CONNECTION_LOST)
+				return
+			}
+			lastActivity = time.Now()
 			bot.handleWebSocketEvent(event)
 		}
 	}
 }
 
+// handleUserAdded greets the channel the bot was just added to
+func (bot *mensabot) handleUserAdded(event *model.WebSocketEvent) {
+	if userID, _ := event.Data["user_id"].(string); userID != bot.user.Id || event.Broadcast == nil {
+		return
+	}
+	channelID := event.Broadcast.ChannelId
+	if CONFIG.DisableGreeting || !bot.isAllowedChannel(channelID) {
+		return
+	}
+
+	slog.Info("Added to channel, sending greeting", "channel_id", channelID)
+	greeting := CONFIG.Greeting
+	if greeting == "" {
+		greeting = tr("greeting")
+	}
+	bot.sendMessage(greeting+"\n\n"+tr("help"), channelID, "")
+}
+
 func (bot *mensabot) handleWebSocketEvent(event *model.WebSocketEvent) {
 	// Skip empty events to avoid noise (especially at shutdown)
 	if event == nil {
 		return
 	}
 
-	// This is for debugging purposes
-	//fmt.Printf("[bot::handleWebSocketEvent] Handling event: %+v\n", event)
+	slog.Debug("Handling event", "event", event.Event, "data", event.Data)
 
-	// We only care about new posts
+	// Introduce ourselves when being added to a channel
+	if event.Event == model.WEBSOCKET_EVENT_USER_ADDED {
+		bot.handleUserAdded(event)
+		return
+	}
+
+	// Answer reactions to our menu posts
+	if event.Event == model.WEBSOCKET_EVENT_REACTION_ADDED {
+		bot.handleReactionAdded(event)
+		return
+	}
+
+	// Otherwise we only care about new posts
 	if event.Event != model.WEBSOCKET_EVENT_POSTED {
 		return
 	}
 
-	post := model.PostFromJson(strings.NewReader(event.Data["post"].(string)))
+	// Malformed events without the post as JSON string must not take down the listener
+	data, ok := event.Data["post"].(string)
+	if !ok {
+		slog.Warn("Ignoring posted event without post data", "data", event.Data)
+		return
+	}
+	post := model.PostFromJson(strings.NewReader(data))
 	if post != nil {
 		// ignore my own posts
 		if post.UserId == bot.user.Id {
 			return
 		}
 
+		// Edits of a post we already answered would otherwise trigger a second reply
+		if post.EditAt != 0 {
+			slog.Debug("Ignoring edited post", "post_id", post.Id)
+			return
+		}
+
+		// Outside of direct messages only respond in the allowed channels
+		channelType, _ := event.Data["channel_type"].(string)
+		if channelType != model.CHANNEL_DIRECT && !bot.isAllowedChannel(post.ChannelId) {
+			return
+		}
+
+		// Posts starting with the command prefix are meant for us as well
+		if CONFIG.CommandPrefix != "" && strings.HasPrefix(post.Message, CONFIG.CommandPrefix) {
+			post.Message = strings.TrimSpace(strings.TrimPrefix(post.Message, CONFIG.CommandPrefix))
+			bot.handleCommand(post)
+			return
+		}
+
+		// Direct messages are always meant for us, so they don't need a mention
+		if channelType == model.CHANNEL_DIRECT {
+			bot.handleCommand(post)
+			return
+		}
+
 		mention, ok := event.Data["mentions"].(string)
 		if ok {
 			// We have some mentions, check if we are one of them
 			var mentions []string
-			json.Unmarshal([]byte(mention), &mentions)
-			if len(mentions) > 3 {
-				// More than 3 mentions is probably @all or @channel, skip those
+			if err := json.Unmarshal([]byte(mention), &mentions); err != nil {
+				// Without readable mentions the post can't be told to be meant for us
+				slog.Warn("Ignoring post with malformed mentions", "post_id", post.Id, "mentions", mention, "error", err)
+				return
+			}
+			if REG_EXP_BROADCAST.MatchString(post.Message) {
+				// Everyone got mentioned by @all, @channel or @here, that's not meant for us
 				return
 			}
 			for _, m := range mentions {
@@ -402,251 +1658,673 @@ func (bot *mensabot) handleWebSocketEvent(event *model.WebSocketEvent) {
 	}
 }
 
-func (bot *mensabot) writeDishes(dishes []dish, prefix string, channelID string, replyToID string) {
+// groupByCategory splits dishes into groups of the same category in the order the categories
+// first appear, keeping the order of the dishes within each group
+func groupByCategory(dishes []dish) (groups [][]dish) {
+	index := make(map[string]int)
+	for _, d := range dishes {
+		i, ok := index[d.category]
+		if !ok {
+			i = len(groups)
+			index[d.category] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], d)
+	}
+	return
+}
+
+// withoutCategories returns copies of the dishes without their categories, so views sorted across
+// the categories render as a single table in their order instead of being grouped again
+func withoutCategories(dishes []dish) []dish {
+	plain := make([]dish, len(dishes))
+	for i, d := range dishes {
+		d.category = ""
+		plain[i] = d
+	}
+	return plain
+}
+
+// dishTable renders dishes as a markdown table, or as one table per category below a subheader
+// if the dishes have categories
+func dishTable(dishes []dish, userID string) string {
+	groups := groupByCategory(dishes)
+	if len(groups) == 1 && groups[0][0].category == "" {
+		return dishTableRows(dishes, userID)
+	}
+
 	var buf bytes.Buffer
+	for i, group := range groups {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if group[0].category != "" {
+			buf.WriteString("**" + group[0].category + "**\n\n")
+		}
+		buf.WriteString(dishTableRows(group, userID))
+	}
+	return buf.String()
+}
 
-	buf.WriteString(prefix + "\n\n")
-	buf.WriteString("| Essen | Features | Preise |\n")
+// dishTableRows renders dishes as a single markdown table
+func dishTableRows(dishes []dish, userID string) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(tr("table.header", strings.Join(CONFIG.PriceLabels, " // ")) + "\n")
 	buf.WriteString("| -- | -- | -- |\n")
 	for _, d := range dishes {
-		buf.WriteString(d.String() + "\n")
+		buf.WriteString(d.render(userID) + "\n")
 	}
+	return buf.String()
+}
 
-	bot.sendMessage(buf.String(), channelID, replyToID)
+// chunkDishes splits dishes into chunks of at most size dishes, size 0 meaning no limit
+func chunkDishes(dishes []dish, size int) (chunks [][]dish) {
+	if size <= 0 || len(dishes) <= size {
+		return [][]dish{dishes}
+	}
+	for len(dishes) > size {
+		chunks = append(chunks, dishes[:size:size])
+		dishes = dishes[size:]
+	}
+	return append(chunks, dishes)
 }
 
-func (bot *mensabot) handleOrder(post *model.Post) {
+// writeDishes posts the dishes below prefix, split into messages of at most MaxDishesPerMessage
+// dishes each so Mattermost doesn't truncate long plans
+func (bot *mensabot) writeDishes(dishes []dish, prefix string, userID string, channelID string, replyToID string) {
+	// Group first so the categories stay together across the chunks
+	var grouped []dish
+	for _, group := range groupByCategory(dishes) {
+		grouped = append(grouped, group...)
+	}
 
-	var cmd string
-	var content string
+	chunks := chunkDishes(grouped, CONFIG.MaxDishesPerMessage)
+	for n, chunk := range chunks {
+		header := prefix
+		if n > 0 {
+			header = tr("plan.continued", n+1, len(chunks))
+		}
+		bot.writeDishChunk(chunk, header, userID, channelID, replyToID)
+	}
+}
 
-	groupNames := REG_EXP_ORDER.SubexpNames()
-	for _, match := range REG_EXP_ORDER.FindAllStringSubmatch(post.Message, -1) {
-		for idx, matchText := range match {
-			name := groupNames[idx]
-			if name == "command" {
-				cmd = matchText
-			} else if name == "content" {
-				content = matchText
-			}
+// writeDishChunk posts the dishes as one message and remembers it for reactions to it
+func (bot *mensabot) writeDishChunk(dishes []dish, prefix string, userID string, channelID string, replyToID string) {
+	if !CONFIG.UseAttachments {
+		post := &model.Post{ChannelId: channelID, Message: prefix + "\n\n" + dishTable(dishes, userID), RootId: replyToID}
+		if created := bot.createPost(post); created != nil {
+			MENU_POSTS.add(created, dishes)
 		}
+		return
 	}
 
-	switch cmd {
-	case "open":
-		if bot.orderDetail != "" {
-			if bot.orderUser == post.UserId {
-				bot.orderDetail = content
-				bot.sendMessage("Updated order details", post.ChannelId, post.Id)
-				break
-			}
-			bot.sendMessage("Not overwriting active order", post.ChannelId, post.Id)
-			break
-		}
-
-		user, _ := bot.client.GetUser(post.UserId, "")
-
-		bot.orderUser = post.UserId
-		bot.orderDetail = content
-		bot.orders = make(map[string]string)
-
-		msg := "#FoodOrder opened by @" + user.Username + ": " + bot.orderDetail
-		bot.sendMessage(msg, post.ChannelId, post.Id)
-		break
-	case "submit":
-		if bot.orderDetail == "" {
-			bot.sendMessage("Cannot submit without active order", post.ChannelId, post.Id)
-			break
-		}
-		bot.orders[post.UserId] = strings.Replace(content, "|", "", -1)
-		break
-	case "list":
-		if bot.orderDetail == "" {
-			bot.sendMessage("Cannot list without active order", post.ChannelId, post.Id)
-			break
-		}
-		msg := "**[Active order]** " + bot.orderDetail + "\n\n"
-		msg += "| User | Order |\n"
-		msg += "| -- | -- |\n"
-		for userId, order := range bot.orders {
-			user, _ := bot.client.GetUser(userId, "")
-			msg += "| @" + user.Username + " | " + order + " |\n"
-		}
-		bot.sendMessage(msg, post.ChannelId, post.Id)
-		break
-	case "close":
-		if bot.orderDetail != "" && bot.orderUser != post.UserId {
-			user, _ := bot.client.GetUser(bot.orderUser, "")
-			msg := "Only @" + user.Username + " can close the active order"
-			bot.sendMessage(msg, post.ChannelId, post.Id)
-			break
-		}
-		if bot.orderDetail != "" {
-			msg := "**Closing** active order:\n\n"
-			msg += "| User | Order |\n"
-			msg += "| -- | -- |\n"
-			for userId, order := range bot.orders {
-				user, _ := bot.client.GetUser(userId, "")
-				msg += "| @" + user.Username + " | " + order + " |\n"
+	// Attachments can't be nested, so the first one of each category carries its name
+	var attachments []*model.SlackAttachment
+	for _, group := range groupByCategory(dishes) {
+		for i, d := range group {
+			a := d.attachment(userID)
+			if i == 0 {
+				a.Pretext = group[0].category
 			}
-			bot.orderDetail = ""
-			bot.orderUser = ""
-			bot.sendMessage(msg, post.ChannelId, post.Id)
+			attachments = append(attachments, a)
+		}
+	}
+	post := &model.Post{ChannelId: channelID, Message: prefix, RootId: replyToID}
+	model.ParseSlackAttachment(post, attachments)
+	if created := bot.createPost(post); created != nil {
+		MENU_POSTS.add(created, dishes)
+	}
+}
+
+// searchTerm returns the query without the words naming the canteen and the modifiers like
+// 'refresh', which go anywhere in a command. A query of nothing else is kept as it is
+func searchTerm(query string, c canteen) string {
+	name := strings.Fields(strings.ToLower(c.Name))
+	var words []string
+	for _, word := range strings.Fields(query) {
+		modifier := REG_EXP_REFRESH.MatchString(word) || REG_EXP_ALL.MatchString(word)
+		for _, n := range name {
+			modifier = modifier || strings.ToLower(word) == n
+		}
+		if !modifier {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return query
+	}
+	return strings.Join(words, " ")
+}
+
+func (bot *mensabot) writeSearch(post *model.Post) {
+	c := selectCanteen(post.Message)
+	query := searchTerm(REG_EXP_SEARCH.FindStringSubmatch(post.Message)[1], c)
+	refresh := REG_EXP_REFRESH.MatchString(post.Message)
+
+	var buf bytes.Buffer
+	for offset, day := range [...]string{tr("day.today"), tr("day.tomorrow")} {
+		dishes, err := getPlan(c.ID, offset, refresh)
+		if err != nil {
+			bot.writeFetchError(err, post.ChannelId, post.Id)
+			return
+		}
+
+		matches := filterDishes(dishes, func(d dish) bool {
+			return strings.Contains(strings.ToLower(d.name), strings.ToLower(query))
+		})
+		matches = visibleDishes(matches, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+		if len(matches) > 0 {
+			buf.WriteString("**" + day + ":**\n\n" + dishTable(matches, post.UserId) + "\n")
 		}
-		break
 	}
 
+	if buf.Len() == 0 {
+		bot.sendMessage(tr("search.none", query), post.ChannelId, post.Id)
+		return
+	}
+	bot.sendMessage(tr("search.header", query, c.suffix())+"\n\n"+buf.String(), post.ChannelId, post.Id)
+}
+
+func (bot *mensabot) writeFilteredDishes(post *model.Post) {
+	offset, day := 0, tr("day.today")
+	if REG_EXP_TOMORROW.MatchString(post.Message) {
+		offset, day = 1, tr("day.tomorrow")
+	}
+
+	pred, label := func(d dish) bool { return d.isVegetarian }, tr("filter.vegetarian")
+	if REG_EXP_VEGAN.MatchString(post.Message) {
+		pred, label = func(d dish) bool { return d.isVegan }, tr("filter.vegan")
+	}
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = filterDishes(dishes, pred)
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("filter.none", day, label), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, "**"+day+" "+label+c.suffix()+":**", post)
+}
+
+// writeDishesUnder posts the dishes whose student price is below the amount given like "unter 2,50"
+func (bot *mensabot) writeDishesUnder(post *model.Post) {
+	offset, day := 0, tr("day.today")
+	if REG_EXP_TOMORROW.MatchString(post.Message) {
+		offset, day = 1, tr("day.tomorrow")
+	}
+
+	arg := REG_EXP_UNDER.FindStringSubmatch(post.Message)[1]
+	limit, ok := parsePrice(strings.TrimSuffix(arg, "€"))
+	if !ok || limit == 0 {
+		bot.sendMessage(tr("under.invalid", arg), post.ChannelId, post.Id)
+		return
+	}
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = filterDishes(dishes, func(d dish) bool {
+		price, ok := d.price(0)
+		return ok && price < limit
+	})
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("under.none", day, formatEuro(limit)), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, tr("under.header", day, formatEuro(limit), c.suffix()), post)
 }
 
+// writeToday posts today's plan and points the user to their favorites on it. The user's dietary
+// profile is applied unless the command contains 'alle'/'all'
+func (bot *mensabot) writeToday(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	if p, ok := PROFILES.get(post.UserId); ok && !REG_EXP_ALL.MatchString(post.Message) {
+		matches := filterDishes(dishes, p.matches)
+		if len(matches) == 0 {
+			bot.sendMessage(tr("profile.nothing", p.label()), post.ChannelId, post.Id)
+			return
+		}
+		bot.replyDishes(matches, tr("profile.header", c.suffix(), p.label()), post)
+		bot.writeFavoriteHighlight(visibleDishes(matches, post.ChannelId, false), post.UserId, post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, tr("plan.today", c.suffix()), post)
+	bot.writeFavoriteHighlight(visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message)), post.UserId, post.ChannelId, post.Id)
+}
+
+// parseAdditiveCodes parses a list of additive codes like "20, 21 22" and also returns the codes as text
+func parseAdditiveCodes(list string) (codes []int, labels []string) {
+	for _, field := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		if n, err := strconv.Atoi(field); err == nil {
+			codes = append(codes, n)
+			labels = append(labels, field)
+		}
+	}
+	return
+}
+
+func (bot *mensabot) writeDishesWithout(post *model.Post) {
+	offset, day := 0, tr("day.today")
+	if REG_EXP_TOMORROW.MatchString(post.Message) {
+		offset, day = 1, tr("day.tomorrow")
+	}
+
+	codes, labels := parseAdditiveCodes(REG_EXP_WITHOUT.FindStringSubmatch(post.Message)[1])
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	label := tr("filter.without", strings.Join(labels, ", "))
+	dishes = filterDishes(dishes, func(d dish) bool { return !d.containsAnyAdditive(codes) })
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("filter.none", day, label), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, "**"+day+" "+label+c.suffix()+":**", post)
+}
+
+func (bot *mensabot) writeRandomDish(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	// Pick from the visible dishes only, so the pick isn't hidden afterwards
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("random.none"), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes([]dish{dishes[rand.Intn(len(dishes))]}, tr("random.header"), post)
+}
+
+// dishSummary counts the dishes per dietary category, leaving out empty categories
+func dishSummary(dishes []dish) string {
+	var vegetarian, vegan, beef, pork, fish, chicken int
+	for _, d := range dishes {
+		if d.isVegan {
+			vegan++
+		} else if d.isVegetarian {
+			vegetarian++
+		}
+		if d.containsBeef {
+			beef++
+		}
+		if d.containsPork {
+			pork++
+		}
+		if d.containsFish {
+			fish++
+		}
+		if d.containsChicken {
+			chicken++
+		}
+	}
+
+	categories := []struct {
+		count int
+		key   string
+	}{
+		{vegetarian, "summary.vegetarian"},
+		{vegan, "summary.vegan"},
+		{beef, "summary.beef"},
+		{pork, "summary.pork"},
+		{fish, "summary.fish"},
+		{chicken, "summary.chicken"},
+	}
+
+	var parts []string
+	for _, c := range categories {
+		switch {
+		case c.count == 1:
+			parts = append(parts, "1 "+tr(c.key+".one"))
+		case c.count > 1:
+			parts = append(parts, strconv.Itoa(c.count)+" "+tr(c.key+".many"))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (bot *mensabot) writeSummary(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), post.ChannelId, post.Id)
+		return
+	}
+
+	summary := dishSummary(dishes)
+	if summary == "" {
+		summary = tr("summary.plain", len(dishes))
+	}
+	bot.sendMessage(tr("summary.header", c.suffix(), summary), post.ChannelId, post.Id)
+}
+
+func (bot *mensabot) writeDishesByPrice(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	// Sort ascending by the student price, dishes without a price go last
+	sorted := withoutCategories(dishes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, okI := sorted[i].price(0)
+		pj, okJ := sorted[j].price(0)
+		if !okI || !okJ {
+			return okI && !okJ
+		}
+		return pi < pj
+	})
+	bot.replyDishes(sorted, tr("cheap.header", c.suffix()), post)
+}
+
+func (bot *mensabot) writeDishesByCalories(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	// Sort ascending by calories, dishes without calorie information go last
+	sorted := withoutCategories(dishes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].calories == 0 || sorted[j].calories == 0 {
+			return sorted[j].calories == 0 && sorted[i].calories != 0
+		}
+		return sorted[i].calories < sorted[j].calories
+	})
+	bot.replyDishes(sorted, tr("calories.header", c.suffix()), post)
+}
+
+// writeLegend explains the emojis of the dish flags, the additives have their own list
 func (bot *mensabot) writeLegend(channelID string, replyToID string) {
-	msg := "**Legende:**\n" +
-		":heart_eyes: = Lieblingsgericht\n" +
-		":sunflower: = Veganes Gericht\n" +
-		":carrot: = Vegetarisches Gericht\n" +
-		":cow2: = Enthält Rindfleisch\n" +
-		":pig2: = Enthält Schweinefleisch\n" +
-		":fish: = Enthält Fisch\n" +
-		":rooster: = Enthält Geflügel\n" +
-		":milk_glass: = Laktose**freies**(!) Gericht\n\n" +
-		"**Zusatzstoffe:**\n" +
-		"1 = Farbstoffe\n" +
-		"2 = Konservierungsstoffe\n" +
-		"3 = Antioxidationsmittel\n" +
-		"4 = Geschmacksverstärker\n" +
-		"5 = Geschwefelt\n" +
-		"6 = Geschwärzt\n" +
-		"7 = Gewachst\n" +
-		"8 = Phosphat\n" +
-		"9 = Süßungsmittel\n" +
-		"10 = Phenylalaninquelle\n" +
-		"14 = enthält glutenhaltiges Getreide (z. B. Weizen, Roggen, Gerste etc.)\n" +
-		"15 = Krebstiere und Krebstiererzeugnisse\n" +
-		"16 = Ei und Eierzeugnisse\n" +
-		"17 = Fisch und Fischerzeugnisse\n" +
-		"18 = Erdnüsse und Erdnusserzeugnisse\n" +
-		"19 = Soja und Sojaerzeugnisse\n" +
-		"20 = Milch und Milcherzeugnisse (einschl. Laktose)\n" +
-		"21 = Schalenfrüchte (z.B. Mandel, Haselnüsse, Walnuss etc.)\n" +
-		"22 = Sellerie und Sellerieerzeugnisse\n" +
-		"23 = Senf und Senferzeugnisse\n" +
-		"24 = Sesamsamen und Sesamsamenerzeugnisse\n" +
-		"25 = Schwefeldioxid und Sulfite (Konzentration über 10mg/kg oder 10mg/l)\n" +
-		"26 = Lupine und - erzeugnisse\n" +
-		"27 = Mollusken/Weichtiere (z.B. Muscheln und Weinbergschnecken)\n"
+	var buf bytes.Buffer
+	buf.WriteString(tr("legend") + "\n")
+	for _, flag := range EMOJI_FLAGS {
+		buf.WriteString(emoji(flag) + " = " + tr("legend."+flag) + "\n")
+	}
+	buf.WriteString("\n" + tr("legend.hint") + "\n")
 
-	bot.sendMessage(msg, channelID, replyToID)
+	bot.sendMessage(buf.String(), channelID, replyToID)
+}
+
+// writeAdditiveList explains all additive codes used on the plans
+func (bot *mensabot) writeAdditiveList(channelID string, replyToID string) {
+	var buf bytes.Buffer
+	buf.WriteString(tr("legend.additives") + "\n")
+	codes := make([]int, 0, len(ADDITIVES[CONFIG.Language]))
+	for code := range ADDITIVES[CONFIG.Language] {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		name, _ := additiveName(code)
+		buf.WriteString(strconv.Itoa(code) + " = " + name + "\n")
+	}
+
+	bot.sendMessage(buf.String(), channelID, replyToID)
+}
+
+// writeAdditives explains the additive codes asked for like "nummer 20"
+func (bot *mensabot) writeAdditives(post *model.Post) {
+	codes, _ := parseAdditiveCodes(REG_EXP_ADDITIVE_LOOKUP.FindStringSubmatch(post.Message)[1])
+
+	var buf bytes.Buffer
+	for _, code := range codes {
+		if name, ok := additiveName(code); ok {
+			buf.WriteString(strconv.Itoa(code) + " = " + name + "\n")
+		} else {
+			buf.WriteString(tr("additive.unknown", code) + "\n")
+		}
+	}
+	bot.sendMessage(buf.String(), post.ChannelId, post.Id)
 }
 
 func (bot *mensabot) writeHelp(channelID string, replyToID string) {
-	msg := "**Need help?** These are my supported commands:\n\n" +
-		"| Command | Keyword(s) (completely case insensitive)|\n" +
-		"| -- | -- |\n" +
-		"| Status | alive, running, up |\n" +
-		"| Today's canteen plan | heute, today, hunger |\n" +
-		"| Tomorrow's canteen plan | morgen, tomorrow |\n" +
-		"| Order controls | order [open, submit, list, close] |\n" +
-		"| Legend | legend(e), zusatzstoff(e), nummer(n) |\n" +
-		"| This help message | command(s), help |\n"
+	bot.sendMessage(tr("help"), channelID, replyToID)
+}
 
-	bot.sendMessage(msg, channelID, replyToID)
+// MARKDOWN_ESCAPER escapes the characters which would format forwarded user text
+var MARKDOWN_ESCAPER = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "#", `\#`, ">", `\>`, "|", `\|`,
+	"[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "!", `\!`,
+	// A zero width space keeps mentions like @all from notifying anyone
+	"@", "@\u200b",
+)
+
+// quoteUserText renders text literally as a block quote
+func quoteUserText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + MARKDOWN_ESCAPER.Replace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// forwardFeedback relays the text after "feedback" to the debug channel, tagged with the sender
+func (bot *mensabot) forwardFeedback(post *model.Post) {
+	text := REG_EXP_FEEDBACK.FindStringSubmatch(post.Message)[1]
+	user := bot.getUser(post.UserId)
+
+	bot.sendMessage(tr("feedback.forwarded", MARKDOWN_ESCAPER.Replace(user.Username))+"\n"+quoteUserText(text), bot.channelDebug.Id, "")
+	bot.sendMessage(tr("feedback.thanks"), post.ChannelId, post.Id)
+}
+
+// writeStatus reports the version, the uptime and when a plan was fetched the last time
+func (bot *mensabot) writeStatus(channelID string, replyToID string) {
+	lastFetch := tr("status.never")
+	if t := PLAN_CACHE.lastFetched(); !t.IsZero() {
+		lastFetch = t.Format("02.01.2006 15:04:05")
+	}
+	uptime := time.Since(START_TIME).Round(time.Second)
+
+	bot.sendMessage(tr("status.up")+"\n\n"+tr("status.details", VERSION, uptime, lastFetch), channelID, replyToID)
 }
 
 func (bot *mensabot) writeMyPleasure(channelID string, replyToID string) {
-	var msgs = [...]string{"My pleasure", "You are very welcome", "Dafür nicht", "Immer gern"}
+	msgs := trChoices("thanks")
 
 	idx := rand.Intn(len(msgs))
 
 	bot.sendMessage(msgs[idx], channelID, replyToID)
 }
 
+func (bot *mensabot) writeCanteens(channelID string, replyToID string) {
+	if len(CONFIG.Canteens) == 0 {
+		bot.sendMessage(tr("canteens.none"), channelID, replyToID)
+		return
+	}
+
+	def := defaultCanteen()
+	msg := tr("canteens.header") + "\n"
+	for _, c := range CONFIG.Canteens {
+		msg += "- " + c.Name
+		if c == def {
+			msg += tr("canteens.default")
+		}
+		msg += "\n"
+	}
+	bot.sendMessage(msg, channelID, replyToID)
+}
+
+// closedMessage is posted instead of an empty plan, day being e.g. tr("when.today")
+func closedMessage(day string) string {
+	return tr("closed", day)
+}
+
+func (bot *mensabot) writeFetchError(err error, channelID string, replyToID string) {
+	slog.Error("Failed to get canteen plan", "channel_id", channelID, "error", err)
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.code < 500 {
+		bot.sendMessage(tr("fetch.missing", statusErr.status), channelID, replyToID)
+		return
+	}
+	bot.sendMessage(tr("fetch.failed"), channelID, replyToID)
+}
+
 func (bot *mensabot) handleCommand(post *model.Post) {
-	if REG_EXP_STATUS.MatchString(post.Message) {
-		// If you see any word matching 'alive'/'running'/'up' then respond with status
-		bot.sendMessage("Yes I'm up and running!", post.ChannelId, post.Id)
+	if !HANDLED_POSTS.markHandled(post.Id) {
+		slog.Debug("Ignoring already handled post", "post_id", post.Id)
 		return
-	} else if REG_EXP_TODAY.MatchString(post.Message) {
-		// If you see any word matching 'heute', 'today' or 'hunger', post today's canteen plan
+	}
 
-		var dishes []dish
-		if !CONFIG.UseMafiasiMensa {
-			dishes = getCanteenPlan(CANTEEN_URL_TODAY)
-		} else {
-			dishes = getCanteenPlanMafiasi(CANTEEN_URL_MAFIASI_TODAY, CONFIG.CanteenIdMafiasi)
+	// Acknowledge the command right away as fetching plans may take a while, and check it off
+	// once done unless it was turned down for the rate limit
+	ran := false
+	bot.addReaction(post.Id, EMOJI_WORKING)
+	defer func() {
+		bot.removeReaction(post.Id, EMOJI_WORKING)
+		if ran {
+			bot.addReaction(post.Id, EMOJI_DONE)
 		}
+	}()
 
-		bot.writeDishes(dishes, "**Heute gibt es:**", post.ChannelId, post.Id)
-	} else if REG_EXP_TOMORROW.MatchString(post.Message) {
-		// If you see any word matching 'morgen' or 'tomorrow', post tomorrow's canteen plan
-		var dishes []dish
-		if !CONFIG.UseMafiasiMensa {
-			dishes = getCanteenPlan(CANTEEN_URL_TOMORROW)
-		} else {
-			dishes = getCanteenPlanMafiasi(CANTEEN_URL_MAFIASI_TOMORROW, CONFIG.CanteenIdMafiasi)
-		}
-		bot.writeDishes(dishes, "**Morgen gibt es:**", post.ChannelId, post.Id)
-	} else if REG_EXP_ORDER.MatchString(post.Message) {
-		bot.handleOrder(post)
-	} else if REG_EXP_LEGEND.MatchString(post.Message) {
-		// If you see any word matching 'legend(e)', 'zusatzstoff(e)', 'inhaltsstoff(e)' or 'nummer(n)', post legend
-		bot.writeLegend(post.ChannelId, post.Id)
-	} else if REG_EXP_HELP.MatchString(post.Message) {
-		// If you see any word matching 'command' or 'help', post available commands
-		bot.writeHelp(post.ChannelId, post.Id)
-	} else if REG_EXP_THANKS.MatchString(post.Message) {
-		bot.writeMyPleasure(post.ChannelId, post.Id)
-	} else {
-		// If nothing matched post a generic message
-		bot.sendMessage("**What does this even mean?!** (Type 'help' to get a list of available commands)", post.ChannelId, post.Id)
+	ran = bot.runCommand(post)
+}
+
+// parseLogLevel parses the LogLevel setting, defaulting to info
+func parseLogLevel(name string) (level slog.Level, err error) {
+	if name != "" {
+		err = level.UnmarshalText([]byte(name))
 	}
+	return
 }
 
 func initialize() {
-	if len(os.Args) < 2 {
-		println("ERROR: MensaBot expects the configuration file as first argument!")
-		os.Exit(1)
-	}
+	dryRun := flag.Bool("dry-run", false, "print replies to stdin commands instead of connecting to Mattermost")
+	flag.Parse()
 
 	// Parse config
-	cfgFile := os.Args[1]
+	cfgFile := flag.Arg(0)
+	if cfgFile == "" {
+		cfgFile = os.Getenv("MENSABOT_CONFIG")
+	}
+	if cfgFile == "" {
+		slog.Error("MensaBot expects the configuration file as first argument or in MENSABOT_CONFIG!")
+		os.Exit(1)
+	}
+	CONFIG_PATH = cfgFile
 	_, err := os.Stat(cfgFile)
 	if err != nil {
-		println("Config file is missing: " + cfgFile)
+		slog.Error("Config file is missing", "path", cfgFile)
 		panic(err)
 	}
 	if _, err := toml.DecodeFile(cfgFile, &CONFIG); err != nil {
 		panic(err)
 	}
+	applyEnv()
+	CONFIG.DryRun = CONFIG.DryRun || *dryRun
+	if err := CONFIG.validate(); err != nil {
+		slog.Error("Invalid configuration", "path", cfgFile, "error", err)
+		os.Exit(1)
+	}
+
+	if err := applyKeywords(CONFIG.Keywords); err != nil {
+		slog.Error("Invalid keyword configuration", "error", err)
+		os.Exit(1)
+	}
+
+	level, err := parseLogLevel(CONFIG.LogLevel)
+	if err != nil {
+		slog.Error("Invalid log level", "level", CONFIG.LogLevel, "error", err)
+		os.Exit(1)
+	}
+	LOG_LEVEL.Set(level)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &LOG_LEVEL})))
+
+	HTTP_CLIENT.Timeout = CONFIG.FetchTimeout.Duration
 
 	// Initialize rand
 	rand.Seed(time.Now().Unix())
 }
 
 func main() {
+	START_TIME = time.Now()
 	initialize()
 
+	if CONFIG.DryRun {
+		newDryRunBot(&CONFIG).runDryRun()
+		return
+	}
+
 	bot := newMensaBotFromConfig(&CONFIG)
-	go bot.startListening()
+	bot.wg.Add(1)
+	go func() {
+		defer bot.wg.Done()
+		bot.startListening()
+	}()
+
+	// Run until a signal or the loss of the connection cancels the bot
+	<-bot.ctx.Done()
+	bot.shutdown()
 
-	// Forever block main routine
-	// TODO |2018-01-17|: It works without this, investigate what the best practices are
-	select {}
+	if err := context.Cause(bot.ctx); !errors.Is(err, context.Canceled) {
+		slog.Error("Stopped", "error", err)
+		os.Exit(1)
+	}
 }
 
-func printError(err *model.AppError) {
-	println("\tError Details:")
-	println("\t\t" + err.Message)
-	println("\t\t" + err.Id)
-	println("\t\t" + err.DetailedError)
+// logAppError logs msg along with the details of a Mattermost API error
+func logAppError(msg string, err *model.AppError, args ...any) {
+	args = append(args, "error_id", err.Id, "error", err.Message, "detail", err.DetailedError)
+	slog.Error(msg, args...)
 }
 
 func printDishes(dishes []dish) {
-	var buf bytes.Buffer
-
-	buf.WriteString("\n\n")
-	buf.WriteString("| Essen | Features | Preise |\n")
-	buf.WriteString("| -- | -- | -- |\n")
-	for _, d := range dishes {
-		buf.WriteString(d.String() + "\n")
-	}
-	fmt.Println(buf.String())
+	fmt.Println("\n\n" + dishTable(dishes, ""))
 }
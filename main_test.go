@@ -0,0 +1,716 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// useConfig replaces CONFIG for the rest of the test
+func useConfig(t *testing.T, cfg config) {
+	t.Helper()
+	running := CONFIG
+	CONFIG = cfg
+	t.Cleanup(func() { CONFIG = running })
+}
+
+// useDryRun switches to a dry run serving the saved plan page for the rest of the test and
+// returns the replies posted meanwhile
+func useDryRun(t *testing.T, plan string) *bytes.Buffer {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.DryRun = true
+	cfg.DryRunPlanFile = "testdata/" + plan
+	useConfig(t, cfg)
+	forgetHandledPosts(t)
+	return captureDryRun(t)
+}
+
+// dryRunReplies runs the message as command of a dry run serving the saved plan page and returns
+// the replies
+func dryRunReplies(t *testing.T, plan string, msg string) string {
+	t.Helper()
+	out := useDryRun(t, plan)
+	newDryRunBot(&CONFIG).runCommand(newDryRunPost(msg))
+	return out.String()
+}
+
+// newDryRunPost returns a post to the dry run channel
+func newDryRunPost(msg string) *model.Post {
+	return &model.Post{Id: "test-post", UserId: DRY_RUN_USER_ID, ChannelId: DRY_RUN_CHANNEL_ID, Message: msg}
+}
+
+// postedEvent builds the event Mattermost sends for a new post in a public channel, mentions
+// being the JSON list of mentioned user IDs or "" if nobody got mentioned
+func postedEvent(post *model.Post, mentions string) *model.WebSocketEvent {
+	data := map[string]interface{}{"post": post.ToJson(), "channel_type": model.CHANNEL_OPEN}
+	if mentions != "" {
+		data["mentions"] = mentions
+	}
+	return &model.WebSocketEvent{
+		Event:     model.WEBSOCKET_EVENT_POSTED,
+		Data:      data,
+		Broadcast: &model.WebsocketBroadcast{ChannelId: post.ChannelId},
+	}
+}
+
+// captureDryRun collects the posts of a dry run for the rest of the test
+func captureDryRun(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	DRY_RUN_OUTPUT = &out
+	t.Cleanup(func() { DRY_RUN_OUTPUT = os.Stdout })
+	return &out
+}
+
+// parseFixture parses the saved plan page in testdata
+func parseFixture(t *testing.T, name string) []dish {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dishes, err := parseCanteenPlan(f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return dishes
+}
+
+func TestParseCanteenPlan(t *testing.T) {
+	useConfig(t, defaultConfig())
+	dishes := parseFixture(t, "plan.html")
+
+	tests := []struct {
+		name         string
+		category     string
+		student      string
+		staff        string
+		isVegetarian bool
+		isVegan      bool
+	}{
+		{"Spaghetti Bolognese (2, 14)", "Hauptgericht", "3,10 €", "4,60 €", false, false},
+		{"Gemüse-Curry mit Reis", "Hauptgericht", "2,50 €", "3,90 €", true, true},
+		{`Seelachsfilet "Müllerin Art" (4)`, "Hauptgericht", "3,40 €", "4,90 €", false, false},
+		{"Spaghetti Bolognese (2, 14)", "Hauptgericht", "3,10 €", "4,60 €", false, false},
+		{"Pommes frites", "Beilage", "1,20 €", "1,80 €", true, false},
+		{"Grießpudding mit Kirschen (3)", "Dessert", "0,90 €", "1,20 €", false, false},
+	}
+	if len(dishes) != len(tests) {
+		t.Fatalf("got %d dishes, want %d: %+v", len(dishes), len(tests), dishes)
+	}
+
+	for i, tt := range tests {
+		d := dishes[i]
+		if d.name != tt.name {
+			t.Errorf("dish %d: name = %q, want %q", i, d.name, tt.name)
+		}
+		if d.category != tt.category {
+			t.Errorf("%s: category = %q, want %q", tt.name, d.category, tt.category)
+		}
+		if d.prices[0] != tt.student || d.prices[1] != tt.staff {
+			t.Errorf("%s: prices = %q, want student %q and staff %q", tt.name, d.prices, tt.student, tt.staff)
+		}
+		if d.isVegetarian != tt.isVegetarian || d.isVegan != tt.isVegan {
+			t.Errorf("%s: vegetarian, vegan = %v, %v, want %v, %v", tt.name, d.isVegetarian, d.isVegan, tt.isVegetarian, tt.isVegan)
+		}
+	}
+}
+
+func TestParseCanteenPlanFlags(t *testing.T) {
+	useConfig(t, defaultConfig())
+	dishes := parseFixture(t, "plan.html")
+
+	if !dishes[0].containsBeef || dishes[0].containsFish {
+		t.Errorf("%s: beef, fish = %v, %v, want true, false", dishes[0].name, dishes[0].containsBeef, dishes[0].containsFish)
+	}
+	if !dishes[1].lactoseFree {
+		t.Errorf("%s: not lactose free", dishes[1].name)
+	}
+	if !dishes[2].containsFish || dishes[2].containsBeef {
+		t.Errorf("%s: beef, fish = %v, %v, want false, true", dishes[2].name, dishes[2].containsBeef, dishes[2].containsFish)
+	}
+	if dishes[4].prices[2] != "" {
+		t.Errorf("%s: guest price = %q, want none", dishes[4].name, dishes[4].prices[2])
+	}
+}
+
+func TestParseCanteenPlanClosed(t *testing.T) {
+	useConfig(t, defaultConfig())
+	if dishes := parseFixture(t, "closed.html"); len(dishes) != 0 {
+		t.Errorf("got %d dishes on the closed page, want none: %+v", len(dishes), dishes)
+	}
+}
+
+func TestClosedPlanReply(t *testing.T) {
+	tests := []struct {
+		msg  string
+		when string
+	}{
+		{"heute", "when.today"},
+		{"was gibt es morgen?", "when.tomorrow"},
+	}
+	for _, tt := range tests {
+		reply := dryRunReplies(t, "closed.html", tt.msg)
+		if !strings.Contains(reply, closedMessage(tr(tt.when))) {
+			t.Errorf("%q: reply %q doesn't say the canteen is closed", tt.msg, reply)
+		}
+		if strings.Contains(reply, "| -- |") {
+			t.Errorf("%q: reply %q contains an empty table", tt.msg, reply)
+		}
+	}
+}
+
+// failingServer fails the first failures requests with the status code, or by dropping the
+// connection if code is 0, and serves a page afterwards. It counts the requests in calls
+func failingServer(t *testing.T, failures int32, code int, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > failures {
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		if code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		code      int
+		wantCalls int32
+		wantCode  int
+	}{
+		{"recovers from server errors", 2, http.StatusServiceUnavailable, 3, 0},
+		{"recovers from dropped connections", 2, 0, 3, 0},
+		{"gives up after the attempts", 5, http.StatusBadGateway, 3, http.StatusBadGateway},
+		{"doesn't retry client errors", 5, http.StatusNotFound, 1, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.FetchAttempts = 3
+			cfg.FetchRetryDelay = duration{time.Millisecond}
+			useConfig(t, cfg)
+
+			var calls atomic.Int32
+			server := failingServer(t, tt.failures, tt.code, &calls)
+
+			resp, err := fetch(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("got %d requests, want %d", got, tt.wantCalls)
+			}
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("fetch failed: %v", err)
+				}
+				return
+			}
+			var statusErr *statusError
+			if !errors.As(err, &statusErr) || statusErr.code != tt.wantCode {
+				t.Errorf("got error %v, want status %d", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMissingPlanReply(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DryRun = true
+	useConfig(t, cfg)
+
+	var calls atomic.Int32
+	server := failingServer(t, 1, http.StatusNotFound, &calls)
+
+	_, err := getCanteenPlan(server.URL)
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.code != http.StatusNotFound {
+		t.Fatalf("got error %v, want status 404", err)
+	}
+
+	out := captureDryRun(t)
+	newDryRunBot(&CONFIG).writeFetchError(err, DRY_RUN_CHANNEL_ID, "")
+	if want := tr("fetch.missing", "404 Not Found"); !strings.Contains(out.String(), want) {
+		t.Errorf("reply %q doesn't contain %q", out.String(), want)
+	}
+}
+
+func TestCommandPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       string
+		mentions  string
+		wantReply bool
+	}{
+		{"prefix", "!mensa heute", "", true},
+		{"mention", "@mensabot heute", `["dry-run-bot"]`, true},
+		{"other mention", "@someone heute", `["someone-else"]`, false},
+		{"neither", "heute gibt es Pizza", "", false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := useDryRun(t, "plan.html")
+			CONFIG.CommandPrefix = "!mensa"
+
+			post := &model.Post{Id: "prefix-post-" + strconv.Itoa(i), UserId: "user", ChannelId: "town-square", Message: tt.msg}
+			newDryRunBot(&CONFIG).handleWebSocketEvent(postedEvent(post, tt.mentions))
+
+			if got := strings.Contains(out.String(), "Spaghetti Bolognese"); got != tt.wantReply {
+				t.Errorf("replied with today's plan: %v, want %v\n%s", got, tt.wantReply, out)
+			}
+		})
+	}
+}
+
+func TestDedupDishes(t *testing.T) {
+	useConfig(t, defaultConfig())
+	dishes, err := readCanteenPlanFile("testdata/plan.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, d := range dishes {
+		names = append(names, d.name)
+	}
+	want := []string{"Spaghetti Bolognese (2, 14)", "Gemüse-Curry mit Reis", `Seelachsfilet "Müllerin Art" (4)`, "Pommes frites", "Grießpudding mit Kirschen (3)"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("got dishes %q, want %q", names, want)
+	}
+
+	// The same dish at another price is a different offer
+	pricier := dishes[0]
+	pricier.prices[0] = "3,50 €"
+	if unique := dedupDishes([]dish{dishes[0], pricier, dishes[0]}); len(unique) != 2 {
+		t.Errorf("got %d dishes, want 2", len(unique))
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   float64
+		wantOK bool
+	}{
+		{"2,50 €", 2.5, true},
+		{"2,50€", 2.5, true},
+		{" 0,90 € ", 0.9, true},
+		{"3.10", 3.1, true},
+		{"4", 4, true},
+		{"", 0, false},
+		{"€", 0, false},
+		{"kostenlos", 0, false},
+		{"-1,00 €", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePrice(tt.s)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("parsePrice(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParsePrices(t *testing.T) {
+	d := dish{priceValues: parsePrices([3]string{"1,20 €", "1,80 €", ""})}
+	if p, ok := d.price(0); !ok || p != 1.2 {
+		t.Errorf("student price = %v, %v, want 1.2, true", p, ok)
+	}
+	if p, ok := d.price(1); !ok || p != 1.8 {
+		t.Errorf("staff price = %v, %v, want 1.8, true", p, ok)
+	}
+	if p, ok := d.price(2); ok {
+		t.Errorf("guest price = %v, want none", p)
+	}
+}
+
+// validConfig returns a config with everything validate requires
+func validConfig() config {
+	cfg := defaultConfig()
+	cfg.MattermostApiURL = "https://chat.example.com"
+	cfg.MattermostWsURL = "wss://chat.example.com"
+	cfg.AuthToken = "token"
+	cfg.TeamName = "team"
+	cfg.ChannelNameDebug = "mensabot-debug"
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *config)
+		want   []string
+	}{
+		{"complete", func(c *config) {}, nil},
+		{"empty", func(c *config) { *c = defaultConfig() }, []string{"MattermostApiURL", "MattermostWsURL", "AuthToken", "TeamName", "ChannelNameDebug"}},
+		{"blank token", func(c *config) { c.AuthToken = "  " }, []string{"AuthToken is missing"}},
+		{"dry run", func(c *config) { *c = defaultConfig(); c.DryRun = true }, nil},
+		{"schedule without channel", func(c *config) { c.ScheduleTime = "11:00" }, []string{"ChannelNameProduction"}},
+		{"invalid schedule time", func(c *config) { c.ChannelNameProduction = "mensa"; c.ScheduleTime = "11.00" }, []string{"ScheduleTime '11.00'"}},
+		{"slash command without server", func(c *config) { c.SlashCommandToken = "secret" }, []string{"ListenAddr"}},
+		{"unknown language", func(c *config) { c.Language = "fr" }, []string{"Language 'fr'"}},
+		{"unknown default canteen", func(c *config) { c.DefaultCanteen = "Mensa Nord" }, []string{"DefaultCanteen 'Mensa Nord'"}},
+		{"invalid quiet dates", func(c *config) { c.QuietDates = []string{"2026-12-24..2026-12-20"} }, []string{"QuietDates"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.change(&cfg)
+
+			err := cfg.validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("got error %v, want none", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error, want one mentioning %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCanteenPlanClassNames(t *testing.T) {
+	page := `<table>
+		<tr><th class="ausgabe">Hauptgericht</th></tr>
+		<tr><td class="gericht">Linsensuppe <img title="vegan"></td><td class="preis">1,90 €</td><td class="preis">2,90 €</td></tr>
+	</table>`
+
+	cfg := defaultConfig()
+	useConfig(t, cfg)
+	if dishes, _ := parseCanteenPlan(strings.NewReader(page)); len(dishes) != 0 {
+		t.Errorf("got %d dishes with the default class names, want none", len(dishes))
+	}
+
+	cfg.DishClass, cfg.PriceClass, cfg.CategoryClass = "gericht", "preis", "ausgabe"
+	useConfig(t, cfg)
+	dishes, err := parseCanteenPlan(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dishes) != 1 {
+		t.Fatalf("got %d dishes with the configured class names, want 1", len(dishes))
+	}
+	d := dishes[0]
+	if d.name != "Linsensuppe" || d.category != "Hauptgericht" || d.prices[0] != "1,90 €" || d.prices[1] != "2,90 €" || !d.isVegan {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestTrimNodeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Pommes frites", "Pommes frites"},
+		{"  Pommes frites  ", "Pommes frites"},
+		{"Pommes   frites", "Pommes frites"},
+		{"Pommes\t\tfrites", "Pommes frites"},
+		{"Gemüse-Curry\n\t   mit Reis", "Gemüse-Curry mit Reis"},
+		{"Pommes\u00a0frites", "Pommes frites"},
+		{"Spaghetti Bolognese ( 2, 14 )", "Spaghetti Bolognese (2, 14)"},
+		{"Reis , Gemüse", "Reis, Gemüse"},
+	}
+	for _, tt := range tests {
+		if got := trimNodeName(tt.name); got != tt.want {
+			t.Errorf("trimNodeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteDishesSplitsLongPlans(t *testing.T) {
+	tests := []struct {
+		max        int
+		wantChunks []int
+	}{
+		{15, []int{15, 15, 10}},
+		{20, []int{20, 20}},
+		{40, []int{40}},
+		{0, []int{40}},
+	}
+
+	var dishes []dish
+	for i := 1; i <= 40; i++ {
+		dishes = append(dishes, dish{name: "Gericht " + strconv.Itoa(i), prices: [3]string{"2,00 €", "3,00 €", "4,00 €"}})
+	}
+
+	for _, tt := range tests {
+		var sizes []int
+		for _, chunk := range chunkDishes(dishes, tt.max) {
+			sizes = append(sizes, len(chunk))
+		}
+		if fmt.Sprint(sizes) != fmt.Sprint(tt.wantChunks) {
+			t.Errorf("chunks of at most %d dishes: got sizes %v, want %v", tt.max, sizes, tt.wantChunks)
+		}
+
+		out := useDryRun(t, "plan.html")
+		CONFIG.MaxDishesPerMessage = tt.max
+		newDryRunBot(&CONFIG).writeDishes(dishes, "**Heute:**", "", DRY_RUN_CHANNEL_ID, "")
+
+		posts := strings.Split(out.String(), "--- [channel")[1:]
+		if len(posts) != len(tt.wantChunks) {
+			t.Fatalf("at most %d dishes: got %d messages, want %d", tt.max, len(posts), len(tt.wantChunks))
+		}
+		header := tr("table.header", strings.Join(CONFIG.PriceLabels, " // "))
+		for i, p := range posts {
+			if !strings.Contains(p, header) {
+				t.Errorf("at most %d dishes: message %d has no table header:\n%s", tt.max, i+1, p)
+			}
+			if rows := strings.Count(p, "| Gericht "); rows != tt.wantChunks[i] {
+				t.Errorf("at most %d dishes: message %d has %d dishes, want %d", tt.max, i+1, rows, tt.wantChunks[i])
+			}
+		}
+	}
+}
+
+func TestPostedEventWithoutPost(t *testing.T) {
+	out := useDryRun(t, "plan.html")
+	bot := newDryRunBot(&CONFIG)
+
+	for _, data := range []map[string]interface{}{
+		nil,
+		{},
+		{"post": nil},
+		{"post": 42},
+		{"post": map[string]interface{}{"message": "heute"}},
+		{"post": "not json"},
+		{"post": "null"},
+	} {
+		event := &model.WebSocketEvent{
+			Event:     model.WEBSOCKET_EVENT_POSTED,
+			Data:      data,
+			Broadcast: &model.WebsocketBroadcast{ChannelId: DRY_RUN_CHANNEL_ID},
+		}
+		bot.handleWebSocketEvent(event)
+	}
+	if out.Len() > 0 {
+		t.Errorf("replied to malformed events:\n%s", out)
+	}
+}
+
+func TestTrimNodeNameEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Pasta &amp; Pesto", "Pasta & Pesto"},
+		{"Grie&szlig;pudding", "Grießpudding"},
+		{"Grie&#223;pudding", "Grießpudding"},
+		{"K&auml;se&nbsp;sp&auml;tzle", "Käse spätzle"},
+		{"Seelachs &quot;M&uuml;llerin Art&quot;", `Seelachs "Müllerin Art"`},
+		{"Salz & Pfeffer", "Salz & Pfeffer"},
+	}
+	for _, tt := range tests {
+		if got := trimNodeName(tt.name); got != tt.want {
+			t.Errorf("trimNodeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsePortion(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Schnitzel 200g", "200 g"},
+		{"Schnitzel (200 g)", "200 g"},
+		{"Apfelschorle 0,5 l", "0,5 l"},
+		{"Smoothie 250 ML", "250 ml"},
+		{"Spaghetti Bolognese (2, 14) 3,10 € 4,60 € 5,60 € 850 kcal", ""},
+		{"Pommes frites", ""},
+		{"Gulasch 12gramm", ""},
+	}
+	for _, tt := range tests {
+		if got := parsePortion(tt.text); got != tt.want {
+			t.Errorf("parsePortion(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCanteenPlanPortions(t *testing.T) {
+	useConfig(t, defaultConfig())
+	for _, d := range parseFixture(t, "plan.html") {
+		want := ""
+		if d.name == "Gemüse-Curry mit Reis" {
+			want = "400 g"
+		}
+		if d.portion != want {
+			t.Errorf("%s: portion = %q, want %q", d.name, d.portion, want)
+		}
+	}
+}
+
+func TestDuplicatePostedEvent(t *testing.T) {
+	out := useDryRun(t, "plan.html")
+	bot := newDryRunBot(&CONFIG)
+
+	post := &model.Post{Id: "duplicate-post", UserId: "user", ChannelId: "town-square", Message: "@mensabot heute"}
+	event := postedEvent(post, `["dry-run-bot"]`)
+	bot.handleWebSocketEvent(event)
+	bot.handleWebSocketEvent(event)
+
+	if replies := strings.Count(out.String(), "Spaghetti Bolognese"); replies != 1 {
+		t.Errorf("got %d replies, want 1:\n%s", replies, out)
+	}
+}
+
+func TestMalformedMentions(t *testing.T) {
+	tests := []struct {
+		mentions  string
+		wantReply bool
+	}{
+		{`["dry-run-bot"]`, true},
+		{`["someone-else", "dry-run-bot"]`, true},
+		{`["dry-run-bot"`, false},
+		{`dry-run-bot`, false},
+		{`{"dry-run-bot": true}`, false},
+	}
+	for i, tt := range tests {
+		out := useDryRun(t, "plan.html")
+		post := &model.Post{Id: "mentions-post-" + strconv.Itoa(i), UserId: "user", ChannelId: "town-square", Message: "@mensabot heute"}
+		newDryRunBot(&CONFIG).handleWebSocketEvent(postedEvent(post, tt.mentions))
+
+		if got := out.Len() > 0; got != tt.wantReply {
+			t.Errorf("mentions %s: replied %v, want %v", tt.mentions, got, tt.wantReply)
+		}
+	}
+}
+
+func TestDishesUnderThreshold(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want []string
+		skip []string
+	}{
+		{"unter 2,60", []string{"Gemüse-Curry", "Pommes frites", "Grießpudding"}, []string{"Spaghetti", "Seelachsfilet"}},
+		{"unter 2,50€", []string{"Pommes frites", "Grießpudding"}, []string{"Gemüse-Curry", "Spaghetti"}},
+		{"under 1", []string{"Grießpudding"}, []string{"Pommes frites"}},
+		{"unter 0,50", []string{tr("under.none", tr("day.today"), formatEuro(0.5))}, []string{"Grießpudding"}},
+		{"unter 0", []string{tr("under.invalid", "0")}, []string{"Grießpudding"}},
+		{"unter 2,5,0", []string{tr("under.invalid", "2,5,0")}, []string{"Grießpudding"}},
+	}
+	for _, tt := range tests {
+		reply := dryRunReplies(t, "plan.html", tt.msg)
+		for _, want := range tt.want {
+			if !strings.Contains(reply, want) {
+				t.Errorf("%q: reply misses %q:\n%s", tt.msg, want, reply)
+			}
+		}
+		for _, skip := range tt.skip {
+			if strings.Contains(reply, skip) {
+				t.Errorf("%q: reply contains %q:\n%s", tt.msg, skip, reply)
+			}
+		}
+	}
+}
+
+func TestSortedViewsKeepTheirOrder(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		// The sort goes across the categories, grouping them again would bring back the plan order
+		{"günstig", []string{"Grießpudding", "Pommes frites", "Gemüse-Curry", "Spaghetti", "Seelachsfilet"}},
+		{"kalorien", []string{"Grießpudding", "Gemüse-Curry", "Pommes frites", "Spaghetti", "Seelachsfilet"}},
+	}
+	for _, tt := range tests {
+		reply := dryRunReplies(t, "plan.html", tt.msg)
+		last := -1
+		for _, want := range tt.want {
+			i := strings.Index(reply, want)
+			if i < 0 {
+				t.Errorf("%q: reply misses %q:\n%s", tt.msg, want, reply)
+				continue
+			}
+			if i < last {
+				t.Errorf("%q: %q listed too early:\n%s", tt.msg, want, reply)
+			}
+			last = i
+		}
+		if strings.Contains(reply, "**Hauptgericht**") || strings.Contains(reply, "**Beilage**") {
+			t.Errorf("%q: sorted reply grouped by category:\n%s", tt.msg, reply)
+		}
+	}
+}
+
+func TestSearchCanteen(t *testing.T) {
+	tests := []struct {
+		msg    string
+		header string
+	}{
+		{"suche spaghetti", tr("search.header", "spaghetti", " (Mensa Studierendenhaus)")},
+		{"suche spaghetti philturm", tr("search.header", "spaghetti", " (Mensa Philturm)")},
+		{"suche spaghetti refresh", tr("search.header", "spaghetti", " (Mensa Studierendenhaus)")},
+		{"search Mensa Philturm spaghetti", tr("search.header", "spaghetti", " (Mensa Philturm)")},
+	}
+	for _, tt := range tests {
+		out := useDryRun(t, "plan.html")
+		CONFIG.Canteens = []canteen{{"Mensa Studierendenhaus", "1"}, {"Mensa Philturm", "2"}}
+		CONFIG.DefaultCanteen = "Mensa Studierendenhaus"
+		newDryRunBot(&CONFIG).runCommand(newDryRunPost(tt.msg))
+
+		if reply := out.String(); !strings.Contains(reply, tt.header) || !strings.Contains(reply, "Spaghetti Bolognese") {
+			t.Errorf("%q: reply misses %q or the dish:\n%s", tt.msg, tt.header, reply)
+		}
+	}
+}
+
+func TestReactionTimesOut(t *testing.T) {
+	cfg := validConfig()
+	cfg.ApiTimeout = duration{50 * time.Millisecond}
+	useConfig(t, cfg)
+
+	// The Mattermost API hangs until the test is over
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// The client gives up much later on its own, like it does in production
+	client := model.NewAPIv4Client(server.URL)
+	client.HttpClient.Timeout = 2 * time.Second
+	bot := &mensabot{client: client, user: &model.User{Id: "bot-id"}}
+	start := time.Now()
+	bot.addReaction("post-id", EMOJI_WORKING)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("adding the reaction took %v, want it to give up after %v", elapsed, cfg.ApiTimeout.Duration)
+	}
+}
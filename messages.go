@@ -0,0 +1,597 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const LANGUAGE_DEFAULT = "de"
+
+// MESSAGES holds the reply texts per language, keyed by message name. Texts may contain
+// fmt verbs which are filled in by tr
+var MESSAGES = map[string]map[string]string{
+	"de": {
+		"bot.started": "_[%s] läuft **jetzt**_",
+		"bot.stopped": "_[%s] wurde **beendet**_",
+
+		"thread.root": "📅 **Mensa am %s, %s**",
+
+		"ical.summary": "Mensa%s",
+
+		"hours.header":     "**Öffnungszeiten:**",
+		"hours.closed":     "geschlossen",
+		"hours.open":       "✅ Die Mensa hat gerade geöffnet, noch bis %s",
+		"hours.now_closed": "❌ Die Mensa hat gerade geschlossen",
+		"hours.unknown":    "Ich kenne die Öffnungszeiten leider nicht",
+
+		"reload.done":      "Die Konfiguration wurde neu geladen. Verbindung, Dateien und Zeitplan ändern sich erst beim Neustart",
+		"reload.failed":    "Die Konfiguration konnte nicht geladen werden, es gilt weiter die alte: %v",
+		"reload.forbidden": "Das dürfen nur Admins",
+
+		"feedback.forwarded": "📬 **Feedback von %s:**",
+		"feedback.thanks":    "Danke, ich habe dein Feedback weitergeleitet!",
+
+		"alert.error":      "⚠️ Der Speiseplan der Mensa %s (in %d Tagen) konnte nicht abgerufen werden: %v",
+		"alert.empty":      "⚠️ Der heutige Speiseplan der Mensa %s ist leer, vielleicht hat sich die Seite geändert",
+		"alert.suppressed": "(%d weitere Warnungen unterdrückt)",
+
+		"greeting":       "Hallo! Ich bin der Mensabot und verrate euch, was es in der Mensa gibt.",
+		"status.up":      "Ja, ich laufe!",
+		"status.details": "Version: %s\nLaufzeit: %s\nLetzter Speiseplan abgerufen: %s",
+		"status.never":   "noch nie",
+		"rate_limited":   "Nicht so schnell! Du schickst diesen Befehl zu oft, versuch es gleich nochmal",
+		"unknown":        "**Was soll das denn heißen?!** (Schreib 'help' für eine Liste der Befehle)",
+		"thanks":         "Dafür nicht|Immer gern|Gern geschehen|Kein Problem",
+
+		"fetch.failed":  "Ich konnte die Seite der Mensa nicht erreichen, versuch es später nochmal",
+		"fetch.missing": "Die Seite der Mensa hat dafür keinen Speiseplan (%s)",
+
+		"day.today":     "Heute",
+		"day.tomorrow":  "Morgen",
+		"when.today":    "heute",
+		"when.tomorrow": "morgen",
+		"when.weekday":  "am %s",
+		"when.date":     "am %s, %s",
+		"and":           "und",
+
+		"weekday.0": "Sonntag",
+		"weekday.1": "Montag",
+		"weekday.2": "Dienstag",
+		"weekday.3": "Mittwoch",
+		"weekday.4": "Donnerstag",
+		"weekday.5": "Freitag",
+		"weekday.6": "Samstag",
+
+		"closed":         "Die Mensa hat %s geschlossen 🍽️",
+		"plan.today":     "**Heute gibt es%s:**",
+		"plan.tomorrow":  "**Morgen gibt es%s:**",
+		"plan.weekday":   "**Am %s gibt es%s:**",
+		"plan.date":      "**Am %s, %s gibt es%s:**",
+		"plan.week":      "**Diese Woche gibt es%s:**",
+		"plan.continued": "_(Fortsetzung %d/%d)_",
+		"plan.mafiasi":   "Mafiasi kennt nur die Speisepläne von heute und morgen",
+		"week.failed":    "_Für %s konnte ich keinen Speiseplan abrufen._",
+
+		"vegan_week.header": "**Diese Woche gibt es vegan%s:**",
+		"vegan_week.none":   "_Keine veganen Gerichte_",
+
+		"favorite_week.header": "**Deine Lieblingsgerichte diese Woche%s:**",
+		"favorite_week.none":   "Diese Woche gibt es leider keins deiner Lieblingsgerichte",
+
+		"table.header":        "| Essen | Features | Preise (%s) |",
+		"attachment.features": "Features",
+
+		"search.header": "**Suche nach '%s'%s:**",
+		"search.none":   "Weder heute noch morgen gibt es etwas mit '%s'",
+
+		"filter.vegetarian": "vegetarisch",
+		"filter.vegan":      "vegan",
+		"filter.without":    "ohne %s",
+		"filter.none":       "%s gibt es leider keine passenden Gerichte (%s)",
+
+		"category.header": "**%s bei %s%s:**",
+		"category.none":   "%s gibt es nichts aus der Kategorie '%s'",
+
+		"next.found": "**Als Nächstes gibt es %s passende Gerichte (%s)%s:**",
+		"next.none":  "In den nächsten Tagen gibt es leider keine passenden Gerichte (%s)",
+
+		"date.invalid": "'%s' ist kein gültiges Datum",
+		"date.range":   "Ich kenne nur die Speisepläne von heute bis in %d Tagen",
+
+		"chart.header": "**Heutige Preise für Studierende%s:**",
+
+		"reaction.vegan":      "**Davon vegan:**",
+		"reaction.vegan.none": "Davon ist leider nichts vegan",
+
+		"statistics.none": "Für diesen Monat habe ich noch keine Speisepläne erfasst",
+		"statistics.month": "**Statistik für diesen Monat%s** (%d erfasste Tage):\n\n" +
+			"- Tage mit veganen Gerichten: %d (%d %%)\n" +
+			"- Tage mit vegetarischen Gerichten: %d (%d %%)\n" +
+			"- Anteil der Gerichte: %d %% vegan, %d %% vegetarisch, %d %% mit Fleisch oder Fisch",
+
+		"new.header": "**Morgen neu%s:**",
+		"new.none":   "Morgen gibt es keine neuen Gerichte, alles gab es heute schon",
+
+		"random.header": "**Wie wäre es mit:**",
+		"random.none":   "Heute gibt es nichts, was ich aussuchen könnte",
+
+		"calories.header": "**Heute nach Kalorien%s:**",
+		"cheap.header":    "**Heute nach Preis%s:**",
+
+		"under.header":  "**%s unter %s%s:**",
+		"under.none":    "%s gibt es leider nichts unter %s",
+		"under.invalid": "'%s' ist kein gültiger Betrag, versuch es z.B. mit 'unter 2,50'",
+
+		"summary.header":          "**Heute%s:** %s",
+		"summary.plain":           "%d Gerichte ohne besondere Kennzeichnung",
+		"summary.vegetarian.one":  "vegetarisches",
+		"summary.vegetarian.many": "vegetarische",
+		"summary.vegan.one":       "veganes",
+		"summary.vegan.many":      "vegane",
+		"summary.beef.one":        "mit Rind",
+		"summary.beef.many":       "mit Rind",
+		"summary.pork.one":        "mit Schwein",
+		"summary.pork.many":       "mit Schwein",
+		"summary.fish.one":        "mit Fisch",
+		"summary.fish.many":       "mit Fisch",
+		"summary.chicken.one":     "mit Geflügel",
+		"summary.chicken.many":    "mit Geflügel",
+
+		"prices.header": "**Preise heute im Vergleich zum letzten Mal%s:**",
+		"prices.table":  "| Essen | Preis | Änderung |",
+		"prices.new":    "neu",
+
+		"average.header": "**Preise für Studierende heute%s:**",
+		"average.body":   "Günstigstes Gericht: %s\nTeuerstes Gericht: %s\nDurchschnitt: %s (aus %d Gerichten mit Preis)",
+		"average.none":   "Heute hat kein Gericht einen Preis",
+
+		"canteens.none":    "Es gibt keine Mensen zur Auswahl, ich kenne nur die Standardmensa",
+		"canteens.header":  "**Mensen:**",
+		"canteens.default": " _(Standard)_",
+
+		"favorites.none":       "Du hast noch keine Lieblingsgerichte, füge eins mit 'favorit add <begriff>' hinzu",
+		"favorites.list":       "**Deine Lieblingsgerichte:** %s",
+		"favorites.which":      "Welches Gericht meinst du? Versuch es mit 'favorit %s schnitzel'",
+		"favorites.added":      "'%s' ist jetzt eins deiner Lieblingsgerichte",
+		"favorites.exists":     "'%s' ist schon eins deiner Lieblingsgerichte",
+		"favorites.removed":    "'%s' ist keins deiner Lieblingsgerichte mehr",
+		"favorites.missing":    "'%s' ist keins deiner Lieblingsgerichte",
+		"favorites.today":      "🎉 Heute gibt es ein Lieblingsgericht: %s",
+		"favorites.today.user": "🎉 Heute gibt es dein Lieblingsgericht: %s",
+
+		"favorites.effective.none":   "Es sind keine Lieblingsgerichte eingestellt, füge eigene mit 'favorit add <begriff>' hinzu",
+		"favorites.effective.config": "**Lieblingsgerichte für alle:**",
+		"favorites.effective.user":   "**Deine eigenen Lieblingsgerichte:**",
+
+		"profile.show":    "**Dein Profil:** %s",
+		"profile.saved":   "Dein Profil ist jetzt: %s. Mit 'heute alle' siehst du trotzdem alle Gerichte",
+		"profile.removed": "Dein Profil wurde gelöscht",
+		"profile.none":    "Du hast kein Profil, leg eins an mit z.B. 'profil vegan ohne 20'",
+		"profile.invalid": "Das habe ich nicht verstanden, versuch es z.B. mit 'profil vegetarisch' oder 'profil ohne 20 21'",
+		"profile.header":  "**Heute gibt es%s für dich (%s):**",
+		"profile.nothing": "Heute gibt es leider nichts für dich (%s), mit 'heute alle' siehst du alle Gerichte",
+
+		"subscription.added":       "Ab jetzt schicke ich dir werktags um %s den Speiseplan",
+		"subscription.exists":      "Du bekommst den Speiseplan schon jeden Werktag",
+		"subscription.removed":     "Ich schicke dir den Speiseplan nicht mehr",
+		"subscription.missing":     "Du hast den Speiseplan nicht abonniert",
+		"subscription.unavailable": "Es ist kein täglicher Speiseplan eingerichtet, den du abonnieren könntest",
+
+		"veggie.added":   "In diesem Kanal zeige ich ab jetzt nur Gerichte ohne Fleisch und Fisch, mit 'alle' (z.B. 'heute alle') siehst du trotzdem alle",
+		"veggie.exists":  "In diesem Kanal zeige ich schon nur Gerichte ohne Fleisch und Fisch",
+		"veggie.removed": "In diesem Kanal zeige ich wieder alle Gerichte",
+		"veggie.missing": "In diesem Kanal zeige ich schon alle Gerichte",
+		"veggie.nothing": "Es gibt nur Gerichte mit Fleisch oder Fisch, die in diesem Kanal ausgeblendet sind. Mit 'alle' zeige ich sie trotzdem",
+
+		"order.table.poll":       "| # | Option | Stimmen |",
+		"order.table.orders":     "| Person | Bestellung |",
+		"order.participants":     "**Teilnehmende:** %d",
+		"order.shopping":         "**Einkaufsliste:**",
+		"order.updated":          "Bestelldetails aktualisiert",
+		"order.not_overwriting":  "Ich überschreibe die laufende Bestellung nicht",
+		"order.opened":           "#FoodOrder von @%s gestartet: %s",
+		"order.poll.invalid":     "Eine Umfrage braucht mindestens zwei Optionen, z.B. 'order poll Pizza | Pasta | Salat'",
+		"order.poll.opened":      "#FoodOrder-Umfrage von @%s gestartet, stimm mit 'order submit <nummer>' ab:",
+		"order.vote.invalid":     "Bitte stimm mit einer Optionsnummer zwischen 1 und %d ab",
+		"order.submit.none":      "Ohne laufende Bestellung kann ich nichts eintragen",
+		"order.withdraw.none":    "Ohne laufende Bestellung kann ich nichts zurückziehen",
+		"order.withdraw.nothing": "Du hast nichts in der laufenden Bestellung, das du zurückziehen könntest",
+		"order.withdrawn":        "Deine Bestellung wurde zurückgezogen",
+		"order.list.none":        "Es gibt keine laufende Bestellung",
+		"order.list.header":      "**[Laufende Bestellung]** %s",
+		"order.close.owner":      "Nur @%s kann die laufende Bestellung schließen",
+		"order.closing":          "Die laufende Bestellung wird **geschlossen**:",
+
+		"legend":             "**Legende:**",
+		"legend.favorite":    "Lieblingsgericht",
+		"legend.vegan":       "Veganes Gericht",
+		"legend.vegetarian":  "Vegetarisches Gericht",
+		"legend.beef":        "Enthält Rindfleisch",
+		"legend.pork":        "Enthält Schweinefleisch",
+		"legend.fish":        "Enthält Fisch",
+		"legend.chicken":     "Enthält Geflügel",
+		"legend.lactosefree": "Laktose**freies**(!) Gericht",
+		"legend.milk":        "Enthält Milch/Laktose (Zusatzstoff 20)",
+		"legend.additives":   "**Zusatzstoffe:**",
+		"legend.hint":        "_Die Zusatzstoffe erkläre ich dir mit 'zusatzstoffe' oder einzeln mit 'nummer <nummern>'_",
+		"additive.unknown":   "Zu Nummer %d gibt es keinen Eintrag",
+
+		"help": "**Brauchst du Hilfe?** Diese Befehle verstehe ich:\n\n" +
+			"| Befehl | Stichwort(e) (Groß-/Kleinschreibung egal) |\n" +
+			"| -- | -- |\n" +
+			"| Status, Version und Laufzeit | alive, running, up, version |\n" +
+			"| Heutiger Speiseplan | heute, today, hunger, speiseplan, menü, essen |\n" +
+			"| Morgiger Speiseplan | morgen, tomorrow |\n" +
+			"| Morgen neue Gerichte | neu morgen, new tomorrow |\n" +
+			"| Nur vegetarische/vegane Gerichte | vegetarisch, veggie, vegan (+ heute/morgen) |\n" +
+			"| Nächster Tag mit vegetarischen/veganen Gerichten | nächster vegan, next veggie |\n" +
+			"| Gerichte ohne bestimmte Zusatzstoffe | ohne/without <nummern> (z.B. ohne 20 21) |\n" +
+			"| Heutige Gerichte nach Kalorien | kalorien, calories, kcal |\n" +
+			"| Heutige Gerichte nach Preis | günstig, billig, cheap |\n" +
+			"| Nur eine Kategorie wie Beilagen | beilagen, hauptgerichte, desserts, suppen, salate (+ heute/morgen) |\n" +
+			"| Gerichte unter einem Preis | unter/under <betrag> (z.B. unter 2,50) |\n" +
+			"| Ein zufälliges Gericht von heute | zufall, random, egal |\n" +
+			"| Heutige Kennzeichnungen auf einen Blick | übersicht, overview, summary |\n" +
+			"| Preisänderungen seit dem letzten Mal | preise, prices |\n" +
+			"| Günstigster, teuerster und durchschnittlicher Preis von heute | durchschnitt, average |\n" +
+			"| Speiseplan als CSV für Tabellen | csv (+ heute/morgen) |\n" +
+			"| Heutige Preise als Diagramm | diagramm, grafik, chart |\n" +
+			"| Vegane und vegetarische Tage in diesem Monat | statistik, statistics |\n" +
+			"| Speisepläne der ganzen Woche | woche, week |\n" +
+			"| Vegane Gerichte der ganzen Woche | vegan woche |\n" +
+			"| Tage mit deinen Lieblingsgerichten diese Woche | lieblings woche |\n" +
+			"| Speiseplan eines Wochentags | montag - freitag, monday - friday |\n" +
+			"| Speiseplan eines Datums in den nächsten zwei Wochen | 24.12., 24.12.2024 |\n" +
+			"| Persönliche Lieblingsgerichte | favorit [add, remove, list] <begriff> |\n" +
+			"| Alle Lieblingsgerichte, die markiert werden | lieblingsgerichte, favorites |\n" +
+			"| Täglichen Speiseplan als Direktnachricht | abo an, abo aus |\n" +
+			"| Fleisch und Fisch in diesem Kanal ausblenden (umgehen mit 'alle') | nurveggie an, nurveggie aus |\n" +
+			"| Ernährungsprofil für 'heute' (umgehen mit 'heute alle') | profil [vegan, vegetarisch] [ohne <nummern>], profil löschen |\n" +
+			"| Heutige und morgige Gerichte durchsuchen | suche, search <begriff> |\n" +
+			"| Mensa auswählen | Namen der Mensa an einen Speiseplan-Befehl anhängen |\n" +
+			"| Mensen auflisten | mensen, canteens |\n" +
+			"| Öffnungszeiten und ob die Mensa gerade offen hat | öffnungszeiten, geöffnet, opening hours |\n" +
+			"| Cache umgehen | refresh, aktualisieren anhängen |\n" +
+			"| Essensbestellungen | order [open, poll, submit, withdraw, list, close] |\n" +
+			"| Legende der Kennzeichnungen | legend(e) |\n" +
+			"| Liste aller Zusatzstoffe | zusatzstoff(e), nummer(n), additives |\n" +
+			"| Bedeutung einzelner Zusatzstoffe | nummer <nummern> (z.B. nummer 20) |\n" +
+			"| Feedback an die Betreiber | feedback <text> |\n" +
+			"| Diese Hilfe | command(s), help |\n",
+	},
+	"en": {
+		"bot.started": "_[%s] has **started** running_",
+		"bot.stopped": "_[%s] has **stopped** running_",
+
+		"thread.root": "📅 **Canteen on %s, %s**",
+
+		"ical.summary": "Canteen%s",
+
+		"hours.header":     "**Opening hours:**",
+		"hours.closed":     "closed",
+		"hours.open":       "✅ The canteen is open right now, until %s",
+		"hours.now_closed": "❌ The canteen is closed right now",
+		"hours.unknown":    "Sorry, I don't know the opening hours",
+
+		"reload.done":      "Reloaded the configuration. Connection, files and schedule only change on restart",
+		"reload.failed":    "Couldn't load the configuration, keeping the old one: %v",
+		"reload.forbidden": "Only admins may do that",
+
+		"feedback.forwarded": "📬 **Feedback from %s:**",
+		"feedback.thanks":    "Thanks, I forwarded your feedback!",
+
+		"alert.error":      "⚠️ Failed to get the plan of canteen %s (in %d days): %v",
+		"alert.empty":      "⚠️ Today's plan of canteen %s is empty, maybe the site layout changed",
+		"alert.suppressed": "(%d more warnings suppressed)",
+
+		"greeting":       "Hi! I'm the canteen bot and tell you what's on the menu.",
+		"status.up":      "Yes I'm up and running!",
+		"status.details": "Version: %s\nUptime: %s\nLast plan fetched: %s",
+		"status.never":   "never",
+		"rate_limited":   "Slow down! You are sending this command too often, try again in a bit",
+		"unknown":        "**What does this even mean?!** (Type 'help' to get a list of available commands)",
+		"thanks":         "My pleasure|You are very welcome|Anytime|No problem",
+
+		"fetch.failed":  "Couldn't reach the canteen site, try again later",
+		"fetch.missing": "The canteen site has no plan for that (%s)",
+
+		"day.today":     "Today",
+		"day.tomorrow":  "Tomorrow",
+		"when.today":    "today",
+		"when.tomorrow": "tomorrow",
+		"when.weekday":  "on %s",
+		"when.date":     "on %s, %s",
+		"and":           "and",
+
+		"weekday.0": "Sunday",
+		"weekday.1": "Monday",
+		"weekday.2": "Tuesday",
+		"weekday.3": "Wednesday",
+		"weekday.4": "Thursday",
+		"weekday.5": "Friday",
+		"weekday.6": "Saturday",
+
+		"closed":         "The canteen is closed %s 🍽️",
+		"plan.today":     "**Today's menu%s:**",
+		"plan.tomorrow":  "**Tomorrow's menu%s:**",
+		"plan.weekday":   "**Menu on %s%s:**",
+		"plan.date":      "**Menu on %s, %s%s:**",
+		"plan.week":      "**This week's menu%s:**",
+		"plan.continued": "_(continued %d/%d)_",
+		"plan.mafiasi":   "Mafiasi only knows today's and tomorrow's plans",
+		"week.failed":    "_I couldn't get the plans for %s._",
+
+		"vegan_week.header": "**This week's vegan dishes%s:**",
+		"vegan_week.none":   "_No vegan dishes_",
+
+		"favorite_week.header": "**Your favorites this week%s:**",
+		"favorite_week.none":   "None of your favorites is on the menu this week",
+
+		"table.header":        "| Dish | Features | Prices (%s) |",
+		"attachment.features": "Features",
+
+		"search.header": "**Search for '%s'%s:**",
+		"search.none":   "There is nothing with '%s' today or tomorrow",
+
+		"filter.vegetarian": "vegetarian",
+		"filter.vegan":      "vegan",
+		"filter.without":    "without %s",
+		"filter.none":       "%s there are no matching dishes (%s)",
+
+		"category.header": "**%s at %s%s:**",
+		"category.none":   "%s there is nothing in the category '%s'",
+
+		"next.found": "**The next matching dishes (%[2]s) are served %[1]s%[3]s:**",
+		"next.none":  "There are no matching dishes (%s) in the next days",
+
+		"date.invalid": "'%s' is not a valid date",
+		"date.range":   "I only know the plans from today up to %d days ahead",
+
+		"chart.header": "**Today's student prices%s:**",
+
+		"reaction.vegan":      "**The vegan ones:**",
+		"reaction.vegan.none": "None of them are vegan",
+
+		"statistics.none": "I haven't recorded any plans this month yet",
+		"statistics.month": "**Statistics for this month%s** (%d recorded days):\n\n" +
+			"- Days with vegan dishes: %d (%d %%)\n" +
+			"- Days with vegetarian dishes: %d (%d %%)\n" +
+			"- Share of dishes: %d %% vegan, %d %% vegetarian, %d %% with meat or fish",
+
+		"new.header": "**New tomorrow%s:**",
+		"new.none":   "There are no new dishes tomorrow, everything is on today's menu as well",
+
+		"random.header": "**How about:**",
+		"random.none":   "There is nothing I could pick from today",
+
+		"calories.header": "**Today by calories%s:**",
+		"cheap.header":    "**Today by price%s:**",
+
+		"under.header":  "**%s under %s%s:**",
+		"under.none":    "%s there is nothing under %s",
+		"under.invalid": "'%s' is not a valid amount, try e.g. 'under 2.50'",
+
+		"summary.header":          "**Today%s:** %s",
+		"summary.plain":           "%d dishes without special labels",
+		"summary.vegetarian.one":  "vegetarian",
+		"summary.vegetarian.many": "vegetarian",
+		"summary.vegan.one":       "vegan",
+		"summary.vegan.many":      "vegan",
+		"summary.beef.one":        "with beef",
+		"summary.beef.many":       "with beef",
+		"summary.pork.one":        "with pork",
+		"summary.pork.many":       "with pork",
+		"summary.fish.one":        "with fish",
+		"summary.fish.many":       "with fish",
+		"summary.chicken.one":     "with poultry",
+		"summary.chicken.many":    "with poultry",
+
+		"prices.header": "**Today's prices compared to last time%s:**",
+		"prices.table":  "| Dish | Price | Change |",
+		"prices.new":    "new",
+
+		"average.header": "**Student prices today%s:**",
+		"average.body":   "Cheapest dish: %s\nMost expensive dish: %s\nAverage: %s (of %d dishes with a price)",
+		"average.none":   "None of today's dishes has a price",
+
+		"canteens.none":    "There are no canteens to choose from, I only know the default one",
+		"canteens.header":  "**Canteens:**",
+		"canteens.default": " _(default)_",
+
+		"favorites.none":       "You have no personal favorites yet, add one with 'favorit add <term>'",
+		"favorites.list":       "**Your favorites:** %s",
+		"favorites.which":      "Which dish do you mean? Try 'favorit %s schnitzel'",
+		"favorites.added":      "Added '%s' to your favorites",
+		"favorites.exists":     "'%s' already is one of your favorites",
+		"favorites.removed":    "Removed '%s' from your favorites",
+		"favorites.missing":    "'%s' is not one of your favorites",
+		"favorites.today":      "🎉 One of the favorites is on the menu today: %s",
+		"favorites.today.user": "🎉 Your favorite is on the menu today: %s",
+
+		"favorites.effective.none":   "No favorites are set, add your own with 'favorit add <term>'",
+		"favorites.effective.config": "**Favorites for everyone:**",
+		"favorites.effective.user":   "**Your own favorites:**",
+
+		"profile.show":    "**Your profile:** %s",
+		"profile.saved":   "Your profile is now: %s. Use 'today all' to see all dishes anyway",
+		"profile.removed": "Your profile was deleted",
+		"profile.none":    "You have no profile, create one with e.g. 'profile vegan without 20'",
+		"profile.invalid": "I didn't get that, try e.g. 'profile vegetarian' or 'profile without 20 21'",
+		"profile.header":  "**Today's menu%s for you (%s):**",
+		"profile.nothing": "There is nothing for you today (%s), use 'today all' to see all dishes",
+
+		"subscription.added":       "From now on I'll send you the menu every weekday at %s",
+		"subscription.exists":      "You already get the menu every weekday",
+		"subscription.removed":     "I won't send you the menu anymore",
+		"subscription.missing":     "You haven't subscribed to the menu",
+		"subscription.unavailable": "There is no daily menu set up you could subscribe to",
+
+		"veggie.added":   "From now on I'll only show dishes without meat and fish in this channel, add 'all' (e.g. 'today all') to see everything",
+		"veggie.exists":  "I already only show dishes without meat and fish in this channel",
+		"veggie.removed": "I'll show all dishes in this channel again",
+		"veggie.missing": "I already show all dishes in this channel",
+		"veggie.nothing": "There are only dishes with meat or fish, which are hidden in this channel. Add 'all' to see them anyway",
+
+		"order.table.poll":       "| # | Option | Votes |",
+		"order.table.orders":     "| User | Order |",
+		"order.participants":     "**Participants:** %d",
+		"order.shopping":         "**Shopping list:**",
+		"order.updated":          "Updated order details",
+		"order.not_overwriting":  "Not overwriting active order",
+		"order.opened":           "#FoodOrder opened by @%s: %s",
+		"order.poll.invalid":     "A poll needs at least two options, e.g. 'order poll Pizza | Pasta | Salad'",
+		"order.poll.opened":      "#FoodOrder poll opened by @%s, vote with 'order submit <number>':",
+		"order.vote.invalid":     "Please vote with an option number between 1 and %d",
+		"order.submit.none":      "Cannot submit without active order",
+		"order.withdraw.none":    "Cannot withdraw without active order",
+		"order.withdraw.nothing": "You have nothing to withdraw from the active order",
+		"order.withdrawn":        "Withdrew your submission from the active order",
+		"order.list.none":        "Cannot list without active order",
+		"order.list.header":      "**[Active order]** %s",
+		"order.close.owner":      "Only @%s can close the active order",
+		"order.closing":          "**Closing** active order:",
+
+		"legend":             "**Legend:**",
+		"legend.favorite":    "Favorite dish",
+		"legend.vegan":       "Vegan dish",
+		"legend.vegetarian":  "Vegetarian dish",
+		"legend.beef":        "Contains beef",
+		"legend.pork":        "Contains pork",
+		"legend.fish":        "Contains fish",
+		"legend.chicken":     "Contains poultry",
+		"legend.lactosefree": "Lactose**free**(!) dish",
+		"legend.milk":        "Contains milk/lactose (additive 20)",
+		"legend.additives":   "**Additives:**",
+		"legend.hint":        "_Ask for 'zusatzstoffe' to get the additives or 'nummer <numbers>' for single ones_",
+		"additive.unknown":   "There is no entry for number %d",
+
+		"help": "**Need help?** These are my supported commands:\n\n" +
+			"| Command | Keyword(s) (completely case insensitive)|\n" +
+			"| -- | -- |\n" +
+			"| Status, version and uptime | alive, running, up, version |\n" +
+			"| Today's canteen plan | heute, today, hunger, speiseplan, menü, essen |\n" +
+			"| Tomorrow's canteen plan | morgen, tomorrow |\n" +
+			"| New dishes tomorrow | neu morgen, new tomorrow |\n" +
+			"| Vegetarian/vegan dishes only | vegetarisch, veggie, vegan (+ heute/morgen) |\n" +
+			"| Next day with vegetarian/vegan dishes | nächster vegan, next veggie |\n" +
+			"| Dishes without certain additives | ohne/without <numbers> (e.g. without 20 21) |\n" +
+			"| Today's dishes sorted by calories | kalorien, calories, kcal |\n" +
+			"| Today's dishes sorted by price | günstig, billig, cheap |\n" +
+			"| Only one category like side dishes | sides, mains, desserts, soups, salads (+ heute/morgen) |\n" +
+			"| Dishes under a price | unter/under <amount> (e.g. under 2.50) |\n" +
+			"| A random dish of today | zufall, random, egal |\n" +
+			"| Today's dietary categories at a glance | übersicht, overview, summary |\n" +
+			"| Price changes since last time | preise, prices |\n" +
+			"| Lowest, highest and average price of today | durchschnitt, average |\n" +
+			"| Menu as CSV for spreadsheets | csv (+ heute/morgen) |\n" +
+			"| Today's prices as chart | diagramm, grafik, chart |\n" +
+			"| Vegan and vegetarian days this month | statistik, statistics |\n" +
+			"| Canteen plans of the whole week | woche, week |\n" +
+			"| Vegan dishes of the whole week | vegan week |\n" +
+			"| Days serving your favorites this week | favorites week |\n" +
+			"| Canteen plan for a weekday | montag - freitag, monday - friday |\n" +
+			"| Canteen plan for a date in the next two weeks | 24.12., 24.12.2024 |\n" +
+			"| Personal favorites | favorit [add, remove, list] <term> |\n" +
+			"| All favorites marked on the plans | lieblingsgerichte, favorites |\n" +
+			"| Daily menu as direct message | abo on, abo off |\n" +
+			"| Hide meat and fish in this channel (bypass with 'all') | nurveggie on, nurveggie off |\n" +
+			"| Dietary profile for 'today' (bypass with 'today all') | profile [vegan, vegetarian] [without <numbers>], profile delete |\n" +
+			"| Search today's and tomorrow's dishes | suche, search <term> |\n" +
+			"| Pick a canteen | add the canteen's name to any plan command |\n" +
+			"| List canteens | mensen, canteens |\n" +
+			"| Opening hours and whether the canteen is open | öffnungszeiten, geöffnet, opening hours |\n" +
+			"| Bypass the plan cache | add refresh, aktualisieren |\n" +
+			"| Order controls | order [open, poll, submit, withdraw, list, close] |\n" +
+			"| Legend of the dish flags | legend(e) |\n" +
+			"| List of all additives | zusatzstoff(e), nummer(n), additives |\n" +
+			"| Meaning of single additives | nummer <numbers> (e.g. nummer 20) |\n" +
+			"| Feedback to the operators | feedback <text> |\n" +
+			"| This help message | command(s), help |\n",
+	},
+}
+
+// ADDITIVES holds the names of the additive codes used on the plans per language
+var ADDITIVES = map[string]map[int]string{
+	"de": {
+		1:  "Farbstoffe",
+		2:  "Konservierungsstoffe",
+		3:  "Antioxidationsmittel",
+		4:  "Geschmacksverstärker",
+		5:  "Geschwefelt",
+		6:  "Geschwärzt",
+		7:  "Gewachst",
+		8:  "Phosphat",
+		9:  "Süßungsmittel",
+		10: "Phenylalaninquelle",
+		14: "enthält glutenhaltiges Getreide (z. B. Weizen, Roggen, Gerste etc.)",
+		15: "Krebstiere und Krebstiererzeugnisse",
+		16: "Ei und Eierzeugnisse",
+		17: "Fisch und Fischerzeugnisse",
+		18: "Erdnüsse und Erdnusserzeugnisse",
+		19: "Soja und Sojaerzeugnisse",
+		20: "Milch und Milcherzeugnisse (einschl. Laktose)",
+		21: "Schalenfrüchte (z.B. Mandel, Haselnüsse, Walnuss etc.)",
+		22: "Sellerie und Sellerieerzeugnisse",
+		23: "Senf und Senferzeugnisse",
+		24: "Sesamsamen und Sesamsamenerzeugnisse",
+		25: "Schwefeldioxid und Sulfite (Konzentration über 10mg/kg oder 10mg/l)",
+		26: "Lupine und - erzeugnisse",
+		27: "Mollusken/Weichtiere (z.B. Muscheln und Weinbergschnecken)",
+	},
+	"en": {
+		1:  "Colorants",
+		2:  "Preservatives",
+		3:  "Antioxidants",
+		4:  "Flavor enhancers",
+		5:  "Sulphurated",
+		6:  "Blackened",
+		7:  "Waxed",
+		8:  "Phosphate",
+		9:  "Sweeteners",
+		10: "Source of phenylalanine",
+		14: "Cereals containing gluten (e.g. wheat, rye, barley etc.)",
+		15: "Crustaceans and products thereof",
+		16: "Eggs and products thereof",
+		17: "Fish and products thereof",
+		18: "Peanuts and products thereof",
+		19: "Soy and products thereof",
+		20: "Milk and products thereof (including lactose)",
+		21: "Nuts (e.g. almonds, hazelnuts, walnuts etc.)",
+		22: "Celery and products thereof",
+		23: "Mustard and products thereof",
+		24: "Sesame seeds and products thereof",
+		25: "Sulphur dioxide and sulphites (concentration above 10mg/kg or 10mg/l)",
+		26: "Lupin and products thereof",
+		27: "Molluscs (e.g. mussels and snails)",
+	},
+}
+
+// additiveName returns the name of the additive code in the configured language
+func additiveName(code int) (string, bool) {
+	name, ok := ADDITIVES[CONFIG.Language][code]
+	return name, ok
+}
+
+// tr looks up the message in the configured language, falling back to the default language,
+// and formats it with args
+func tr(key string, args ...any) string {
+	format, ok := MESSAGES[CONFIG.Language][key]
+	if !ok {
+		format = MESSAGES[LANGUAGE_DEFAULT][key]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// trChoices returns the alternatives of a message separated by "|"
+func trChoices(key string) []string {
+	return strings.Split(tr(key), "|")
+}
+
+// weekdayName returns the localized name of day
+func weekdayName(day time.Weekday) string {
+	return tr(fmt.Sprintf("weekday.%d", day))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// counterVec is a set of counters distinguished by a single label value
+type counterVec struct {
+	sync.RWMutex
+	values map[string]*atomic.Uint64
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{values: make(map[string]*atomic.Uint64)}
+}
+
+func (c *counterVec) inc(label string) {
+	c.RLock()
+	counter, ok := c.values[label]
+	c.RUnlock()
+
+	if !ok {
+		c.Lock()
+		if counter, ok = c.values[label]; !ok {
+			counter = new(atomic.Uint64)
+			c.values[label] = counter
+		}
+		c.Unlock()
+	}
+	counter.Add(1)
+}
+
+// sorted returns the labels in order along with their current values
+func (c *counterVec) sorted() (labels []string, values []uint64) {
+	c.RLock()
+	defer c.RUnlock()
+
+	for label := range c.values {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		values = append(values, c.values[label].Load())
+	}
+	return
+}
+
+var METRICS_COMMANDS = newCounterVec()
+var METRICS_ORDERS_OPENED atomic.Uint64
+
+// handleMetrics serves the counters in the Prometheus text exposition format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP mensabot_commands_total Number of handled commands by command, unknown being unmatched messages.")
+	fmt.Fprintln(w, "# TYPE mensabot_commands_total counter")
+	labels, values := METRICS_COMMANDS.sorted()
+	for i, label := range labels {
+		fmt.Fprintf(w, "mensabot_commands_total{command=%q} %d\n", label, values[i])
+	}
+
+	fmt.Fprintln(w, "# HELP mensabot_orders_opened_total Number of opened food orders and polls.")
+	fmt.Fprintln(w, "# TYPE mensabot_orders_opened_total counter")
+	fmt.Fprintf(w, "mensabot_orders_opened_total %d\n", METRICS_ORDERS_OPENED.Load())
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+var (
+	ERR_NO_ORDER      = errors.New("no active order")
+	ERR_NOT_OWNER     = errors.New("the active order belongs to someone else")
+	ERR_INVALID_VOTE  = errors.New("vote is not an option number")
+	ERR_NO_SUBMISSION = errors.New("nothing submitted to the active order")
+)
+
+// order is the active food order of a channel
+type order struct {
+	User        string            `json:"user"`
+	Detail      string            `json:"detail"`
+	Submissions map[string]string `json:"submissions"`
+
+	// Options are set for polls, whose submissions are 1-based option numbers
+	Options []string `json:"options,omitempty"`
+}
+
+// parsePollOptions splits "Pizza | Pasta | Salad" into its options
+func parsePollOptions(content string) (options []string) {
+	for _, o := range strings.Split(content, "|") {
+		if o = strings.TrimSpace(o); o != "" {
+			options = append(options, o)
+		}
+	}
+	return
+}
+
+// clone copies the order so it can be read without holding the store's lock
+func (o *order) clone() *order {
+	c := *o
+	c.Submissions = make(map[string]string, len(o.Submissions))
+	for userID, submission := range o.Submissions {
+		c.Submissions[userID] = submission
+	}
+	c.Options = append([]string(nil), o.Options...)
+	return &c
+}
+
+// orderStore holds the active order of each channel, keyed by channel ID. All methods return
+// copies of the orders, so callers never share state with the store
+type orderStore struct {
+	sync.Mutex
+	path   string
+	orders map[string]*order
+}
+
+var ORDERS = orderStore{orders: make(map[string]*order)}
+
+// load restores the orders from path and persists every following change there
+func (s *orderStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	orders := make(map[string]*order)
+	if err := loadJSON(path, &orders); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable order file", "error", err)
+		}
+		return
+	}
+
+	for channelID, o := range orders {
+		if o == nil {
+			delete(orders, channelID)
+		} else if o.Submissions == nil {
+			o.Submissions = make(map[string]string)
+		}
+	}
+	s.orders = orders
+	slog.Info("Restored order state", "path", path, "orders", len(orders))
+}
+
+// save must be called with the lock held
+func (s *orderStore) save() {
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.orders); err != nil {
+		slog.Error("Failed to persist order state", "error", err)
+	}
+}
+
+// open starts an order in the channel, or updates its details if the user already owns the
+// active one, which is reported by updated
+func (s *orderStore) open(channelID string, userID string, detail string) (o *order, updated bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if active := s.orders[channelID]; active != nil {
+		if active.User != userID {
+			return nil, false, ERR_NOT_OWNER
+		}
+		active.Detail = detail
+		s.save()
+		return active.clone(), true, nil
+	}
+
+	active := &order{User: userID, Detail: detail, Submissions: make(map[string]string)}
+	s.orders[channelID] = active
+	s.save()
+	return active.clone(), false, nil
+}
+
+// poll starts a poll in the channel. Changing the options invalidates all votes, so a poll
+// replacing the user's active order always starts from scratch
+func (s *orderStore) poll(channelID string, userID string, options []string) (*order, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if active := s.orders[channelID]; active != nil && active.User != userID {
+		return nil, ERR_NOT_OWNER
+	}
+
+	active := &order{User: userID, Detail: strings.Join(options, " | "), Submissions: make(map[string]string), Options: options}
+	s.orders[channelID] = active
+	s.save()
+	return active.clone(), nil
+}
+
+// submit stores the user's submission, for polls content must be an option number
+func (s *orderStore) submit(channelID string, userID string, content string) (*order, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	active := s.orders[channelID]
+	if active == nil {
+		return nil, ERR_NO_ORDER
+	}
+	if active.Options != nil {
+		n, err := strconv.Atoi(strings.TrimSpace(content))
+		if err != nil || n < 1 || n > len(active.Options) {
+			return active.clone(), ERR_INVALID_VOTE
+		}
+		content = strconv.Itoa(n)
+	} else {
+		content = strings.Replace(content, "|", "", -1)
+	}
+
+	active.Submissions[userID] = content
+	s.save()
+	return active.clone(), nil
+}
+
+// withdraw removes the user's submission from the active order
+func (s *orderStore) withdraw(channelID string, userID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	active := s.orders[channelID]
+	if active == nil {
+		return ERR_NO_ORDER
+	}
+	if _, ok := active.Submissions[userID]; !ok {
+		return ERR_NO_SUBMISSION
+	}
+	delete(active.Submissions, userID)
+	s.save()
+	return nil
+}
+
+// list returns the active order of the channel
+func (s *orderStore) list(channelID string) (*order, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	active := s.orders[channelID]
+	if active == nil {
+		return nil, ERR_NO_ORDER
+	}
+	return active.clone(), nil
+}
+
+// close ends the active order of the channel, only its owner may do so. The closed order is
+// returned even if the user isn't its owner, so the owner can be named
+func (s *orderStore) close(channelID string, userID string) (*order, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	active := s.orders[channelID]
+	if active == nil {
+		return nil, ERR_NO_ORDER
+	}
+	if active.User != userID {
+		return active.clone(), ERR_NOT_OWNER
+	}
+	delete(s.orders, channelID)
+	s.save()
+	return active, nil
+}
+
+// orderTable renders the submissions of an order, or the votes per option for polls
+func (bot *mensabot) orderTable(o *order) string {
+	if o.Options != nil {
+		votes := make([]int, len(o.Options))
+		for _, submission := range o.Submissions {
+			if n, err := strconv.Atoi(submission); err == nil && n >= 1 && n <= len(votes) {
+				votes[n-1]++
+			}
+		}
+
+		msg := tr("order.table.poll") + "\n"
+		msg += "| -- | -- | -- |\n"
+		for i, option := range o.Options {
+			msg += "| " + strconv.Itoa(i+1) + " | " + option + " | " + strconv.Itoa(votes[i]) + " |\n"
+		}
+		return msg
+	}
+
+	msg := tr("order.table.orders") + "\n"
+	msg += "| -- | -- |\n"
+	for userId, submission := range o.Submissions {
+		user := bot.getUser(userId)
+		msg += "| @" + user.Username + " | " + submission + " |\n"
+	}
+	return msg
+}
+
+// orderSummary counts the participants and sums up identical items like "2x Currywurst"
+func orderSummary(o *order) string {
+	msg := tr("order.participants", len(o.Submissions)) + "\n"
+	if o.Options != nil || len(o.Submissions) == 0 {
+		return msg
+	}
+
+	counts := make(map[string]int)
+	names := make(map[string]string)
+	for _, submission := range o.Submissions {
+		quantity, item := 1, strings.TrimSpace(submission)
+		if match := REG_EXP_QUANTITY.FindStringSubmatch(item); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil {
+				quantity, item = n, match[2]
+			}
+		}
+
+		key := strings.ToLower(strings.Join(strings.Fields(item), " "))
+		if key == "" {
+			continue
+		}
+		if _, ok := names[key]; !ok {
+			names[key] = strings.Join(strings.Fields(item), " ")
+		}
+		counts[key] += quantity
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msg += "\n" + tr("order.shopping") + "\n"
+	for _, key := range keys {
+		msg += "- " + strconv.Itoa(counts[key]) + "x " + names[key] + "\n"
+	}
+	return msg
+}
+
+func (bot *mensabot) handleOrder(post *model.Post) {
+
+	var cmd string
+	var content string
+
+	groupNames := REG_EXP_ORDER.SubexpNames()
+	for _, match := range REG_EXP_ORDER.FindAllStringSubmatch(post.Message, -1) {
+		for idx, matchText := range match {
+			name := groupNames[idx]
+			if name == "command" {
+				cmd = strings.ToLower(matchText)
+			} else if name == "content" {
+				content = strings.TrimSpace(matchText)
+			}
+		}
+	}
+
+	switch cmd {
+	case "open":
+		active, updated, err := ORDERS.open(post.ChannelId, post.UserId, content)
+		if err != nil {
+			bot.sendMessage(tr("order.not_overwriting"), post.ChannelId, post.Id)
+			break
+		}
+		if updated {
+			bot.sendMessage(tr("order.updated"), post.ChannelId, post.Id)
+			break
+		}
+		METRICS_ORDERS_OPENED.Add(1)
+
+		user := bot.getUser(post.UserId)
+		bot.sendMessage(tr("order.opened", user.Username, active.Detail), post.ChannelId, post.Id)
+	case "poll":
+		options := parsePollOptions(content)
+		if len(options) < 2 {
+			bot.sendMessage(tr("order.poll.invalid"), post.ChannelId, post.Id)
+			break
+		}
+		if _, err := ORDERS.poll(post.ChannelId, post.UserId, options); err != nil {
+			bot.sendMessage(tr("order.not_overwriting"), post.ChannelId, post.Id)
+			break
+		}
+		METRICS_ORDERS_OPENED.Add(1)
+
+		user := bot.getUser(post.UserId)
+		msg := tr("order.poll.opened", user.Username) + "\n\n"
+		for i, o := range options {
+			msg += strconv.Itoa(i+1) + ". " + o + "\n"
+		}
+		bot.sendMessage(msg, post.ChannelId, post.Id)
+	case "submit":
+		active, err := ORDERS.submit(post.ChannelId, post.UserId, content)
+		switch {
+		case errors.Is(err, ERR_NO_ORDER):
+			bot.sendMessage(tr("order.submit.none"), post.ChannelId, post.Id)
+		case errors.Is(err, ERR_INVALID_VOTE):
+			bot.sendMessage(tr("order.vote.invalid", len(active.Options)), post.ChannelId, post.Id)
+		}
+	case "withdraw":
+		switch err := ORDERS.withdraw(post.ChannelId, post.UserId); {
+		case errors.Is(err, ERR_NO_ORDER):
+			bot.sendMessage(tr("order.withdraw.none"), post.ChannelId, post.Id)
+		case errors.Is(err, ERR_NO_SUBMISSION):
+			bot.sendMessage(tr("order.withdraw.nothing"), post.ChannelId, post.Id)
+		default:
+			bot.sendMessage(tr("order.withdrawn"), post.ChannelId, post.Id)
+		}
+	case "list":
+		active, err := ORDERS.list(post.ChannelId)
+		if err != nil {
+			bot.sendMessage(tr("order.list.none"), post.ChannelId, post.Id)
+			break
+		}
+		msg := tr("order.list.header", active.Detail) + "\n\n"
+		msg += bot.orderTable(active)
+		bot.sendMessage(msg, post.ChannelId, post.Id)
+	case "close":
+		active, err := ORDERS.close(post.ChannelId, post.UserId)
+		if errors.Is(err, ERR_NOT_OWNER) {
+			user := bot.getUser(active.User)
+			bot.sendMessage(tr("order.close.owner", user.Username), post.ChannelId, post.Id)
+			break
+		}
+		if err == nil {
+			msg := tr("order.closing") + "\n\n"
+			msg += bot.orderTable(active) + "\n"
+			msg += orderSummary(active)
+			bot.sendMessage(msg, post.ChannelId, post.Id)
+		}
+	}
+
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+func TestOrderStoreConcurrentSubmits(t *testing.T) {
+	s := orderStore{orders: make(map[string]*order)}
+	if _, _, err := s.open("channel", "owner", "Pizza"); err != nil {
+		t.Fatal(err)
+	}
+
+	const users = 100
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			if _, err := s.submit("channel", userID, "Margherita"); err != nil {
+				t.Error(err)
+			}
+			if _, err := s.list("channel"); err != nil {
+				t.Error(err)
+			}
+		}("user-" + strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	o, err := s.list("channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Submissions) != users {
+		t.Errorf("got %d submissions, want %d", len(o.Submissions), users)
+	}
+}
+
+func TestOrderTableUserLookupFailure(t *testing.T) {
+	useConfig(t, defaultConfig())
+
+	// The Mattermost API knows alice but fails to look up anyone else
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/users/alice-id") {
+			w.Write([]byte(`{"id": "alice-id", "username": "alice"}`))
+			return
+		}
+		http.Error(w, `{"id": "api.context.500", "message": "internal error"}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	bot := &mensabot{client: model.NewAPIv4Client(server.URL)}
+	o := &order{User: "alice-id", Detail: "Pizza", Submissions: map[string]string{"alice-id": "Margherita", "bob-id": "Funghi"}}
+
+	table := bot.orderTable(o)
+	for _, want := range []string{"| @alice | Margherita |", "| @bob-id | Funghi |"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("order table misses %q:\n%s", want, table)
+		}
+	}
+}
+
+func TestOrderRegexp(t *testing.T) {
+	tests := []struct {
+		msg         string
+		wantCommand string
+		wantContent string
+	}{
+		{"@mensabot order open Pizza um 12", "open", "Pizza um 12"},
+		{"@mensabot  Order open Pizza", "open", "Pizza"},
+		{"ORDER   submit  Margherita", "submit", "Margherita"},
+		{"  order list", "list", ""},
+		{"@mensa.bot order\tCLOSE", "CLOSE", ""},
+		{"@mensabot order poll Pizza | Pasta | Salad", "poll", "Pizza | Pasta | Salad"},
+		{"reorder open Pizza", "", ""},
+		{"my order open Pizza", "", ""},
+		{"order opened", "", ""},
+	}
+
+	for _, tt := range tests {
+		match := REG_EXP_ORDER.FindStringSubmatch(tt.msg)
+		if tt.wantCommand == "" {
+			if match != nil {
+				t.Errorf("%q matched %q, want no match", tt.msg, match)
+			}
+			continue
+		}
+		if match == nil {
+			t.Errorf("%q didn't match", tt.msg)
+			continue
+		}
+		command := match[REG_EXP_ORDER.SubexpIndex("command")]
+		content := match[REG_EXP_ORDER.SubexpIndex("content")]
+		if command != tt.wantCommand || content != tt.wantContent {
+			t.Errorf("%q parsed as %q, %q, want %q, %q", tt.msg, command, content, tt.wantCommand, tt.wantContent)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// PRICE_HISTORY_SIZE bounds the number of remembered dishes, the ones seen longest ago are dropped first
+const PRICE_HISTORY_SIZE = 1000
+
+// priceRecord is the last student price of a dish and the one of the day before it was last seen
+type priceRecord struct {
+	Price         string `json:"price"`
+	Date          string `json:"date"`
+	PreviousPrice string `json:"previous_price,omitempty"`
+}
+
+// priceHistory remembers the prices of dishes per canteen, keyed by priceKey
+type priceHistory struct {
+	sync.Mutex
+	path    string
+	records map[string]priceRecord
+}
+
+var PRICES = priceHistory{records: make(map[string]priceRecord)}
+
+// priceKey identifies a dish of a canteen across days
+func priceKey(canteenID string, name string) string {
+	return canteenID + "|" + normalizeDishName(name)
+}
+
+// normalizeDishName strips additive codes, case and spacing from name so the same dish compares
+// equal across days
+func normalizeDishName(name string) string {
+	name = REG_EXP_ADDITIVES.ReplaceAllString(name, "")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// load restores the history from path and persists every following change there
+func (h *priceHistory) load(path string) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.path = path
+	if path == "" {
+		return
+	}
+
+	records := make(map[string]priceRecord)
+	if err := loadJSON(path, &records); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable price history", "error", err)
+		}
+		return
+	}
+	h.records = records
+	slog.Info("Restored price history", "path", path, "dishes", len(records))
+}
+
+// record stores today's prices of the canteen's dishes
+func (h *priceHistory) record(canteenID string, dishes []dish) {
+	h.Lock()
+	defer h.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	changed := false
+	for _, d := range dishes {
+		if d.prices[0] == "" {
+			continue
+		}
+		key := priceKey(canteenID, d.name)
+		rec, ok := h.records[key]
+		switch {
+		case !ok:
+			rec = priceRecord{Price: d.prices[0], Date: today}
+		case rec.Date != today:
+			rec = priceRecord{Price: d.prices[0], Date: today, PreviousPrice: rec.Price}
+		case rec.Price != d.prices[0]:
+			rec.Price = d.prices[0]
+		default:
+			continue
+		}
+		h.records[key] = rec
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	h.prune()
+	if h.path == "" {
+		return
+	}
+	if err := saveJSON(h.path, h.records); err != nil {
+		slog.Error("Failed to persist price history", "error", err)
+	}
+}
+
+// prune must be called with the lock held
+func (h *priceHistory) prune() {
+	if len(h.records) <= PRICE_HISTORY_SIZE {
+		return
+	}
+
+	keys := make([]string, 0, len(h.records))
+	for key := range h.records {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return h.records[keys[i]].Date < h.records[keys[j]].Date })
+	for _, key := range keys[:len(keys)-PRICE_HISTORY_SIZE] {
+		delete(h.records, key)
+	}
+}
+
+// previous returns the price the dish had the last day it was seen before today
+func (h *priceHistory) previous(canteenID string, name string) (string, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	rec, ok := h.records[priceKey(canteenID, name)]
+	if !ok {
+		return "", false
+	}
+	if rec.Date != time.Now().Format("2006-01-02") {
+		return rec.Price, true
+	}
+	return rec.PreviousPrice, rec.PreviousPrice != ""
+}
+
+// priceChange describes how the student price of the dish changed since it was last seen
+func priceChange(canteenID string, d dish) string {
+	previous, ok := PRICES.previous(canteenID, d.name)
+	if !ok {
+		return tr("prices.new")
+	}
+
+	now, okNow := d.price(0)
+	before, okBefore := parsePrice(previous)
+	switch {
+	case !okNow || !okBefore:
+		return "-"
+	case now > before:
+		return "📈 +" + formatEuro(now-before) + " (" + previous + ")"
+	case now < before:
+		return "📉 -" + formatEuro(before-now) + " (" + previous + ")"
+	default:
+		return "="
+	}
+}
+
+// formatEuro formats amount the way the canteen site does, e.g. "0,20 €"
+func formatEuro(amount float64) string {
+	return strings.Replace(fmt.Sprintf("%.2f €", amount), ".", ",", 1)
+}
+
+// writePriceAverage posts the lowest, highest and average student price of today's dishes,
+// leaving out the dishes without a price
+func (bot *mensabot) writePriceAverage(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), post.ChannelId, post.Id)
+		return
+	}
+
+	var lowest, highest, sum float64
+	count := 0
+	for _, d := range dishes {
+		price, ok := d.price(0)
+		if !ok {
+			continue
+		}
+		if count == 0 || price < lowest {
+			lowest = price
+		}
+		if count == 0 || price > highest {
+			highest = price
+		}
+		sum += price
+		count++
+	}
+	if count == 0 {
+		bot.sendMessage(tr("average.none"), post.ChannelId, post.Id)
+		return
+	}
+
+	msg := tr("average.header", c.suffix()) + "\n\n"
+	msg += tr("average.body", formatEuro(lowest), formatEuro(highest), formatEuro(sum/float64(count)), count)
+	bot.sendMessage(msg, post.ChannelId, post.Id)
+}
+
+func (bot *mensabot) writePriceChanges(post *model.Post) {
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, 0, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(tr("when.today")), post.ChannelId, post.Id)
+		return
+	}
+
+	dishes = visibleDishes(dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+	if len(dishes) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), post.ChannelId, post.Id)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(tr("prices.header", c.suffix()) + "\n\n")
+	buf.WriteString(tr("prices.table") + "\n")
+	buf.WriteString("| -- | -- | -- |\n")
+	for _, d := range dishes {
+		price := d.prices[0]
+		if price == "" {
+			price = "-"
+		}
+		buf.WriteString("| " + d.displayName() + " | " + price + " | " + priceChange(c.ID, d) + " |\n")
+	}
+	bot.sendMessage(buf.String(), post.ChannelId, post.Id)
+}
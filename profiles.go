@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// profile is a user's standing filter applied to their plain plan requests
+type profile struct {
+	Vegetarian bool  `json:"vegetarian,omitempty"`
+	Vegan      bool  `json:"vegan,omitempty"`
+	Without    []int `json:"without,omitempty"`
+}
+
+// matches reports whether the dish fits the profile
+func (p profile) matches(d dish) bool {
+	if p.Vegan && !d.isVegan || p.Vegetarian && !d.isVegetarian {
+		return false
+	}
+	return !d.containsAnyAdditive(p.Without)
+}
+
+// label describes the profile like the filter commands do, e.g. "vegan, ohne 20"
+func (p profile) label() string {
+	var parts []string
+	switch {
+	case p.Vegan:
+		parts = append(parts, tr("filter.vegan"))
+	case p.Vegetarian:
+		parts = append(parts, tr("filter.vegetarian"))
+	}
+	if len(p.Without) > 0 {
+		codes := make([]string, len(p.Without))
+		for i, c := range p.Without {
+			codes[i] = strconv.Itoa(c)
+		}
+		parts = append(parts, tr("filter.without", strings.Join(codes, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// profileStore holds the dietary profile of each user, keyed by user ID
+type profileStore struct {
+	sync.Mutex
+	path     string
+	profiles map[string]profile
+}
+
+var PROFILES = profileStore{profiles: make(map[string]profile)}
+
+// load restores the profiles from path and persists every following change there
+func (s *profileStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	profiles := make(map[string]profile)
+	if err := loadJSON(path, &profiles); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable profiles file", "error", err)
+		}
+		return
+	}
+	s.profiles = profiles
+	slog.Info("Restored dietary profiles", "path", path, "users", len(profiles))
+}
+
+// save must be called with the lock held
+func (s *profileStore) save() {
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.profiles); err != nil {
+		slog.Error("Failed to persist dietary profiles", "error", err)
+	}
+}
+
+func (s *profileStore) set(userID string, p profile) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.profiles[userID] = p
+	s.save()
+}
+
+// remove deletes the user's profile and reports whether there was one
+func (s *profileStore) remove(userID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.profiles[userID]; !ok {
+		return false
+	}
+	delete(s.profiles, userID)
+	s.save()
+	return true
+}
+
+func (s *profileStore) get(userID string) (profile, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	p, ok := s.profiles[userID]
+	p.Without = append([]int(nil), p.Without...)
+	return p, ok
+}
+
+// handleProfile shows, sets or deletes the dietary profile of the user, e.g. "profil vegan ohne 20"
+func (bot *mensabot) handleProfile(post *model.Post) {
+	args := strings.TrimSpace(REG_EXP_PROFILE.FindStringSubmatch(post.Message)[1])
+
+	switch {
+	case args == "":
+		p, ok := PROFILES.get(post.UserId)
+		if !ok {
+			bot.sendMessage(tr("profile.none"), post.ChannelId, post.Id)
+			return
+		}
+		bot.sendMessage(tr("profile.show", p.label()), post.ChannelId, post.Id)
+	case REG_EXP_PROFILE_DELETE.MatchString(args):
+		if PROFILES.remove(post.UserId) {
+			bot.sendMessage(tr("profile.removed"), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("profile.none"), post.ChannelId, post.Id)
+		}
+	default:
+		p := profile{
+			Vegan:      REG_EXP_VEGAN.MatchString(args),
+			Vegetarian: REG_EXP_VEGETARIAN.MatchString(args),
+		}
+		if match := REG_EXP_WITHOUT.FindStringSubmatch(args); match != nil {
+			p.Without, _ = parseAdditiveCodes(match[1])
+		}
+		if p.label() == "" {
+			bot.sendMessage(tr("profile.invalid"), post.ChannelId, post.Id)
+			return
+		}
+		PROFILES.set(post.UserId, p)
+		bot.sendMessage(tr("profile.saved", p.label()), post.ChannelId, post.Id)
+	}
+}
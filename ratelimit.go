@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter allows each user a limited number of runs per command within a sliding window
+type rateLimiter struct {
+	sync.Mutex
+	runs      map[string][]time.Time
+	lastPrune time.Time
+}
+
+var RATE_LIMITER = rateLimiter{runs: make(map[string][]time.Time)}
+
+// allow records a run of the command by the user and reports whether it is within the limit
+func (l *rateLimiter) allow(userID string, command string) bool {
+	limit, window := CONFIG.RateLimit, CONFIG.RateLimitWindow.Duration
+	if limit <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.prune(now, window)
+
+	key := userID + "|" + command
+	runs := recentRuns(l.runs[key], now, window)
+	if len(runs) >= limit {
+		l.runs[key] = runs
+		return false
+	}
+	l.runs[key] = append(runs, now)
+	return true
+}
+
+// prune drops the users without recent runs once per window so one-off users don't pile up
+func (l *rateLimiter) prune(now time.Time, window time.Duration) {
+	if now.Sub(l.lastPrune) < window {
+		return
+	}
+	l.lastPrune = now
+
+	for key, runs := range l.runs {
+		if runs = recentRuns(runs, now, window); len(runs) == 0 {
+			delete(l.runs, key)
+		} else {
+			l.runs[key] = runs
+		}
+	}
+}
+
+// recentRuns returns the runs within the window before now, runs being in chronological order
+func recentRuns(runs []time.Time, now time.Time, window time.Duration) []time.Time {
+	for i, run := range runs {
+		if now.Sub(run) < window {
+			return runs[i:]
+		}
+	}
+	return nil
+}
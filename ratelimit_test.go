@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.RateLimit = 5
+	cfg.RateLimitWindow = duration{time.Minute}
+	useConfig(t, cfg)
+
+	l := rateLimiter{runs: make(map[string][]time.Time)}
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.allow("user", "today") {
+				allowed.Add(1)
+			}
+			l.allow("other-user", "week")
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != 5 {
+		t.Errorf("allowed %d runs, want 5", got)
+	}
+}
+
+func TestRateLimitedCommandDoesNotRun(t *testing.T) {
+	out := useDryRun(t, "plan.html")
+	CONFIG.RateLimit = 1
+	CONFIG.RateLimitWindow = duration{time.Minute}
+	RATE_LIMITER.Lock()
+	RATE_LIMITER.runs = make(map[string][]time.Time)
+	RATE_LIMITER.Unlock()
+
+	bot := newDryRunBot(&CONFIG)
+	if !bot.runCommand(newDryRunPost("heute")) {
+		t.Fatal("first command reported as not run")
+	}
+	out.Reset()
+	if bot.runCommand(newDryRunPost("heute")) {
+		t.Error("rate limited command reported as run")
+	}
+	if reply := out.String(); !strings.Contains(reply, tr("rate_limited")) {
+		t.Errorf("rate limited command replied:\n%s", reply)
+	}
+}
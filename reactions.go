@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// MENU_POSTS_SIZE bounds the number of remembered menu posts, the oldest are forgotten first
+const MENU_POSTS_SIZE = 200
+
+// REACTION_LEGEND is the emoji which asks for the legend when reacting to a menu post, the
+// emoji of the vegan flag asks for the vegan dishes
+const REACTION_LEGEND = "grey_question"
+
+// menuPost is a post of the bot listing dishes, remembered to answer reactions to it
+type menuPost struct {
+	channelID string
+	rootID    string
+	dishes    []dish
+}
+
+// menuPostStore remembers the last menu posts of the bot by post ID
+type menuPostStore struct {
+	sync.Mutex
+	posts map[string]menuPost
+	ring  []string
+	next  int
+}
+
+var MENU_POSTS = menuPostStore{posts: make(map[string]menuPost)}
+
+// add remembers the post, which lists the dishes
+func (s *menuPostStore) add(post *model.Post, dishes []dish) {
+	s.Lock()
+	defer s.Unlock()
+
+	// Replies have to go to the root of a thread
+	rootID := post.RootId
+	if rootID == "" {
+		rootID = post.Id
+	}
+
+	if len(s.ring) < MENU_POSTS_SIZE {
+		s.ring = append(s.ring, post.Id)
+	} else {
+		delete(s.posts, s.ring[s.next])
+		s.ring[s.next] = post.Id
+		s.next = (s.next + 1) % MENU_POSTS_SIZE
+	}
+	s.posts[post.Id] = menuPost{channelID: post.ChannelId, rootID: rootID, dishes: dishes}
+}
+
+func (s *menuPostStore) get(postID string) (menuPost, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	p, ok := s.posts[postID]
+	return p, ok
+}
+
+// handleReactionAdded answers reactions to the bot's menu posts, posting the legend for
+// REACTION_LEGEND and the vegan dishes for the vegan emoji in the thread
+func (bot *mensabot) handleReactionAdded(event *model.WebSocketEvent) {
+	if CONFIG.DisableReactionTriggers {
+		return
+	}
+	data, ok := event.Data["reaction"].(string)
+	if !ok {
+		return
+	}
+	reaction := model.ReactionFromJson(strings.NewReader(data))
+	if reaction == nil || reaction.UserId == bot.user.Id {
+		return
+	}
+	menu, ok := MENU_POSTS.get(reaction.PostId)
+	if !ok {
+		return
+	}
+
+	var command string
+	switch reaction.EmojiName {
+	case REACTION_LEGEND:
+		command = "legend"
+	case strings.Trim(emoji("vegan"), ":"):
+		command = "vegan"
+	default:
+		return
+	}
+
+	slog.Info("Handling reaction", "command", command, "post_id", reaction.PostId, "user_id", reaction.UserId)
+	METRICS_COMMANDS.inc("reaction")
+	if !RATE_LIMITER.allow(reaction.UserId, "reaction") {
+		return
+	}
+
+	if command == "legend" {
+		bot.writeLegend(menu.channelID, menu.rootID)
+		return
+	}
+	vegan := filterDishes(menu.dishes, func(d dish) bool { return d.isVegan })
+	if len(vegan) == 0 {
+		bot.sendMessage(tr("reaction.vegan.none"), menu.channelID, menu.rootID)
+		return
+	}
+	bot.writeDishes(vegan, tr("reaction.vegan"), reaction.UserId, menu.channelID, menu.rootID)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// CONFIG_LOCK guards CONFIG, the keyword regexes and the bot's channels against reloads. Reloads
+// run on the web socket event loop, which therefore reads them without locking. The HTTP handlers
+// hold the read lock while they work, the scheduler only while taking the settings of its post
+var CONFIG_LOCK sync.RWMutex
+
+// isAdmin reports whether the user is listed in the Admins config
+func isAdmin(userID string) bool {
+	for _, id := range CONFIG.Admins {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// keepStartupSettings copies the settings which only take effect on startup from the running config,
+// i.e. the connection, the HTTP server, the persistence files and the schedule
+func (c *config) keepStartupSettings(running *config) {
+	c.MattermostApiURL = running.MattermostApiURL
+	c.MattermostWsURL = running.MattermostWsURL
+	c.AuthToken = running.AuthToken
+	c.TeamName = running.TeamName
+	c.ListenAddr = running.ListenAddr
+	c.SlashCommandToken = running.SlashCommandToken
+	c.ApiTimeout = running.ApiTimeout
+	c.PingInterval = running.PingInterval
+	c.PingTimeout = running.PingTimeout
+	c.OrderFile = running.OrderFile
+	c.FavoritesFile = running.FavoritesFile
+	c.SubscriptionFile = running.SubscriptionFile
+	c.ProfileFile = running.ProfileFile
+	c.VeggieFile = running.VeggieFile
+	c.PriceHistoryFile = running.PriceHistoryFile
+	c.StatisticsFile = running.StatisticsFile
+	c.ScheduleTime = running.ScheduleTime
+	c.DryRun = running.DryRun
+	c.DryRunPlanFile = running.DryRunPlanFile
+}
+
+// reloadConfig reads the config file again and applies it. Everything is checked and looked up
+// first, so an invalid file leaves the running config untouched, and then applied at once under
+// CONFIG_LOCK. It must only be called from the web socket event loop
+func (bot *mensabot) reloadConfig() error {
+	cfg := defaultConfig()
+	if _, err := toml.DecodeFile(CONFIG_PATH, &cfg); err != nil {
+		return err
+	}
+	cfg.keepStartupSettings(&CONFIG)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	keywords, err := compileKeywords(cfg.Keywords)
+	if err != nil {
+		return fmt.Errorf("invalid keyword configuration: %w", err)
+	}
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	// A dry run has no channels to look up
+	channelDebug, channelProduction, allowedChannels := bot.channelDebug, bot.channelProduction, bot.allowedChannels
+	if !cfg.DryRun {
+		var appErr *model.AppError
+		if channelDebug, appErr = bot.lookupChannel(cfg.ChannelNameDebug); appErr != nil {
+			return fmt.Errorf("debug channel '%s': %s", cfg.ChannelNameDebug, appErr.Message)
+		}
+		channelProduction = nil
+		if cfg.ChannelNameProduction != "" {
+			if channelProduction, appErr = bot.lookupChannel(cfg.ChannelNameProduction); appErr != nil {
+				return fmt.Errorf("production channel '%s': %s", cfg.ChannelNameProduction, appErr.Message)
+			}
+		}
+		allowedChannels = bot.lookupAllowedChannels(cfg.AllowedChannels)
+	}
+
+	CONFIG_LOCK.Lock()
+	defer CONFIG_LOCK.Unlock()
+
+	CONFIG = cfg
+	for command, re := range KEYWORD_DEFAULTS {
+		*KEYWORD_COMMANDS[command] = re
+	}
+	for command, re := range keywords {
+		*KEYWORD_COMMANDS[command] = re
+	}
+	LOG_LEVEL.Set(level)
+	HTTP_CLIENT.Timeout = CONFIG.FetchTimeout.Duration
+	bot.channelDebug = channelDebug
+	bot.channelProduction = channelProduction
+	bot.allowedChannels = allowedChannels
+
+	slog.Info("Reloaded config", "path", CONFIG_PATH)
+	return nil
+}
+
+func (bot *mensabot) handleReload(post *model.Post) {
+	if !isAdmin(post.UserId) {
+		bot.sendMessage(tr("reload.forbidden"), post.ChannelId, post.Id)
+		return
+	}
+
+	if err := bot.reloadConfig(); err != nil {
+		slog.Error("Failed to reload config", "path", CONFIG_PATH, "error", err)
+		bot.sendMessage(tr("reload.failed", err), post.ChannelId, post.Id)
+		return
+	}
+	bot.sendMessage(tr("reload.done"), post.ChannelId, post.Id)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReloadDuringRequests(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.DryRun = true
+	useConfig(t, cfg)
+
+	path := filepath.Join(t.TempDir(), "mensabot.toml")
+	if err := os.WriteFile(path, []byte("Language = \"en\"\n[Keywords]\ntoday = [\"mahlzeit\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	running := CONFIG_PATH
+	CONFIG_PATH = path
+	t.Cleanup(func() {
+		CONFIG_PATH = running
+		for command, re := range KEYWORD_DEFAULTS {
+			*KEYWORD_COMMANDS[command] = re
+		}
+	})
+
+	handler := readingConfig(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tr("when.today") + " " + REG_EXP_TODAY.String()))
+	})
+
+	bot := newDryRunBot(&CONFIG)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/menu/today", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			CONFIG_LOCK.RLock()
+			defer CONFIG_LOCK.RUnlock()
+			bot.isAllowedChannel("town-square")
+		}()
+	}
+	if err := bot.reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if CONFIG.Language != "en" || !REG_EXP_TODAY.MatchString("mahlzeit") {
+		t.Errorf("reload wasn't applied: language %q, today %q", CONFIG.Language, REG_EXP_TODAY)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	_ "time/tzdata" // the schedule must not depend on the host's zoneinfo
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const SCHEDULE_TIMEZONE = "Europe/Berlin"
+
+type schedule struct {
+	location *time.Location
+	hour     int
+	minute   int
+}
+
+// parseSchedule parses a daily "HH:MM" post time interpreted in SCHEDULE_TIMEZONE
+func parseSchedule(at string) (*schedule, error) {
+	location, err := time.LoadLocation(SCHEDULE_TIMEZONE)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule{location, t.Hour(), t.Minute()}, nil
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// next returns the first scheduled weekday post strictly after now
+func (s *schedule) next(now time.Time) time.Time {
+	now = now.In(s.location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, s.location)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	for isWeekend(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runSchedule posts the plan at the scheduled times until the bot is cancelled
+func (bot *mensabot) runSchedule() {
+	for {
+		next := bot.schedule.next(time.Now())
+		slog.Info("Scheduled next post", "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-bot.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		bot.postScheduledPlan()
+	}
+}
+
+// parseQuietDates parses a date like "2026-12-24" or an inclusive range like "2026-12-20..2027-01-06"
+// into the first and last quiet day
+func parseQuietDates(dates string, location *time.Location) (first time.Time, last time.Time, err error) {
+	from, to, isRange := strings.Cut(dates, "..")
+	if first, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(from), location); err != nil {
+		return
+	}
+	last = first
+	if isRange {
+		if last, err = time.ParseInLocation("2006-01-02", strings.TrimSpace(to), location); err != nil {
+			return
+		}
+		if last.Before(first) {
+			err = fmt.Errorf("'%s' ends before it starts", dates)
+		}
+	}
+	return
+}
+
+// parseQuietHours parses a daily window like "18:00-08:00" into minutes after midnight, it may
+// span midnight
+func parseQuietHours(window string) (from int, to int, err error) {
+	start, end, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("'%s' is not a time window like 18:00-08:00", window)
+	}
+	s, err := time.Parse("15:04", strings.TrimSpace(start))
+	if err != nil {
+		return 0, 0, err
+	}
+	e, err := time.Parse("15:04", strings.TrimSpace(end))
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.Hour()*60 + s.Minute(), e.Hour()*60 + e.Minute(), nil
+}
+
+// isQuiet reports whether automatic posts are suppressed at t because it falls on one of the
+// QuietDates or into the QuietHours. The settings were validated on startup
+func (s *schedule) isQuiet(t time.Time) bool {
+	t = t.In(s.location)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.location)
+	for _, dates := range CONFIG.QuietDates {
+		first, last, err := parseQuietDates(dates, s.location)
+		if err == nil && !day.Before(first) && !day.After(last) {
+			return true
+		}
+	}
+
+	if CONFIG.QuietHours == "" {
+		return false
+	}
+	from, to, err := parseQuietHours(CONFIG.QuietHours)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// dailyThread is the pinned root post the scheduled plan of a day is posted below
+type dailyThread struct {
+	date   string
+	postID string
+}
+
+// todaysThread returns the ID of today's pinned thread in the channel, starting it and unpinning
+// the previous one if necessary. Without a thread "" is returned, so the plan becomes a root post
+func (bot *mensabot) todaysThread(channelID string) string {
+	now := time.Now().In(bot.schedule.location)
+	today := now.Format("2006-01-02")
+	if bot.dailyThread.date == today {
+		return bot.dailyThread.postID
+	}
+
+	if previous := bot.dailyThread.postID; previous != "" {
+		if resp := withTimeout("UnpinPost", func() (resp *model.Response) {
+			_, resp = bot.client.UnpinPost(previous)
+			return
+		}); resp.Error != nil {
+			logAppError("Failed to unpin the previous daily thread", resp.Error, "post_id", previous)
+		}
+	}
+	bot.dailyThread = dailyThread{}
+
+	root := bot.createPost(&model.Post{
+		ChannelId: channelID,
+		Message:   tr("thread.root", weekdayName(now.Weekday()), now.Format("02.01.2006")),
+	})
+	if root == nil {
+		return ""
+	}
+	if resp := withTimeout("PinPost", func() (resp *model.Response) {
+		_, resp = bot.client.PinPost(root.Id)
+		return
+	}); resp.Error != nil {
+		logAppError("Failed to pin the daily thread", resp.Error, "post_id", root.Id)
+	}
+
+	bot.dailyThread = dailyThread{date: today, postID: root.Id}
+	return root.Id
+}
+
+// postScheduledPlan posts today's plan to the production channel and sends it to the subscribers,
+// who aren't bothered on closed days
+func (bot *mensabot) postScheduledPlan() {
+	// Take what the post needs under the read lock, but don't hold it while fetching and posting,
+	// as a reload would wait for all of that and stall the event loop meanwhile
+	CONFIG_LOCK.RLock()
+	quiet := bot.schedule.isQuiet(time.Now())
+	c := defaultCanteen()
+	channelID := bot.channelProduction.Id
+	threaded := CONFIG.ScheduleThread
+	header, closed := tr("plan.today", c.suffix()), closedMessage(tr("when.today"))
+	CONFIG_LOCK.RUnlock()
+
+	if quiet {
+		slog.Info("Skipping scheduled post during quiet time")
+		return
+	}
+
+	dishes, err := getPlan(c.ID, 0, false)
+	if err != nil {
+		slog.Error("Failed to get canteen plan for scheduled post", "canteen", c.ID, "error", err)
+		return
+	}
+
+	rootID := ""
+	if threaded {
+		rootID = bot.todaysThread(channelID)
+	}
+
+	if len(dishes) == 0 {
+		bot.sendMessage(closed, channelID, rootID)
+		return
+	}
+	bot.writeVisibleDishes(dishes, header, "", channelID, rootID, false)
+	bot.writeFavoriteAnnouncements(visibleDishes(dishes, channelID, false), channelID, rootID)
+	bot.sendSubscriptions(dishes, header)
+}
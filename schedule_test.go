@@ -0,0 +1,135 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+func TestParseQuietDates(t *testing.T) {
+	tests := []struct {
+		dates     string
+		wantFirst string
+		wantLast  string
+		wantErr   bool
+	}{
+		{"2026-12-24", "2026-12-24", "2026-12-24", false},
+		{"2026-12-20..2027-01-06", "2026-12-20", "2027-01-06", false},
+		{" 2026-12-20 .. 2027-01-06 ", "2026-12-20", "2027-01-06", false},
+		{"2027-01-06..2026-12-20", "", "", true},
+		{"24.12.2026", "", "", true},
+		{"2026-12-20..", "", "", true},
+	}
+	for _, tt := range tests {
+		first, last, err := parseQuietDates(tt.dates, time.UTC)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseQuietDates(%q): got error %v, want error %v", tt.dates, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (first.Format("2006-01-02") != tt.wantFirst || last.Format("2006-01-02") != tt.wantLast) {
+			t.Errorf("parseQuietDates(%q) = %v..%v, want %s..%s", tt.dates, first, last, tt.wantFirst, tt.wantLast)
+		}
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		window   string
+		from, to int
+		wantErr  bool
+	}{
+		{"18:00-08:00", 18 * 60, 8 * 60, false},
+		{"12:30 - 13:15", 12*60 + 30, 13*60 + 15, false},
+		{"18:00", 0, 0, true},
+		{"18-08", 0, 0, true},
+		{"25:00-08:00", 0, 0, true},
+	}
+	for _, tt := range tests {
+		from, to, err := parseQuietHours(tt.window)
+		if (err != nil) != tt.wantErr || (err == nil && (from != tt.from || to != tt.to)) {
+			t.Errorf("parseQuietHours(%q) = %d, %d, %v, want %d, %d, error %v", tt.window, from, to, err, tt.from, tt.to, tt.wantErr)
+		}
+	}
+}
+
+func TestIsQuiet(t *testing.T) {
+	s, err := parseSchedule("11:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(value string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", value, s.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		dates []string
+		hours string
+		at    string
+		want  bool
+	}{
+		{"nothing configured", nil, "", "2026-12-24 11:00", false},
+		{"inside the break", []string{"2026-12-20..2027-01-06"}, "", "2026-12-28 11:00", true},
+		{"first day of the break", []string{"2026-12-20..2027-01-06"}, "", "2026-12-20 00:00", true},
+		{"last day of the break", []string{"2026-12-20..2027-01-06"}, "", "2027-01-06 23:59", true},
+		{"after the break", []string{"2026-12-20..2027-01-06"}, "", "2027-01-07 11:00", false},
+		{"holiday", []string{"2026-10-03", "2026-12-20..2027-01-06"}, "", "2026-10-03 11:00", true},
+		{"inside the hours", nil, "10:00-12:00", "2026-10-16 11:00", true},
+		{"end of the hours", nil, "10:00-12:00", "2026-10-16 12:00", false},
+		{"overnight evening", nil, "18:00-08:00", "2026-10-16 22:00", true},
+		{"overnight morning", nil, "18:00-08:00", "2026-10-16 07:59", true},
+		{"outside overnight hours", nil, "18:00-08:00", "2026-10-16 11:00", false},
+	}
+	for _, tt := range tests {
+		cfg := defaultConfig()
+		cfg.QuietDates, cfg.QuietHours = tt.dates, tt.hours
+		useConfig(t, cfg)
+
+		if got := s.isQuiet(at(tt.at)); got != tt.want {
+			t.Errorf("%s: isQuiet(%s) = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+// lockProbe takes the dry-run posts and counts the ones written while CONFIG_LOCK was held
+type lockProbe struct {
+	posts, locked int
+}
+
+func (p *lockProbe) Write(b []byte) (int, error) {
+	p.posts++
+	if CONFIG_LOCK.TryLock() {
+		CONFIG_LOCK.Unlock()
+	} else {
+		p.locked++
+	}
+	return len(b), nil
+}
+
+func TestScheduledPlanDoesNotBlockReloads(t *testing.T) {
+	useDryRun(t, "plan.html")
+	var probe lockProbe
+	DRY_RUN_OUTPUT = &probe
+
+	bot := newDryRunBot(&CONFIG)
+	bot.channelProduction = &model.Channel{Id: "production-channel"}
+	schedule, err := parseSchedule("11:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.schedule = schedule
+
+	bot.postScheduledPlan()
+	if probe.posts == 0 {
+		t.Fatal("nothing was posted")
+	}
+	if probe.locked > 0 {
+		t.Errorf("%d of %d posts were written holding the config lock", probe.locked, probe.posts)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const SERVER_SHUTDOWN_TIMEOUT = 5 * time.Second
+
+// health tracks the state reported by the /health endpoint
+type health struct {
+	connected   atomic.Bool
+	fetchFailed atomic.Bool
+}
+
+var HEALTH health
+
+func (h *health) recordFetch(err error) {
+	h.fetchFailed.Store(err != nil)
+}
+
+func (h *health) ok() bool {
+	return h.connected.Load() && !h.fetchFailed.Load()
+}
+
+func (bot *mensabot) startServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/menu/today", readingConfig(menuHandler(0)))
+	mux.HandleFunc("/menu/tomorrow", readingConfig(menuHandler(1)))
+	mux.HandleFunc("/menu/week.ics", readingConfig(handleCalendar))
+	if CONFIG.SlashCommandToken != "" {
+		mux.HandleFunc("/command", readingConfig(handleSlashCommand))
+	}
+
+	bot.server = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		slog.Info("Starting HTTP server", "addr", addr)
+		if err := bot.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "addr", addr, "error", err)
+		}
+	}()
+}
+
+// readingConfig holds the read lock of CONFIG_LOCK while the handler runs, so a reload can't
+// change the config midway through a request
+func readingConfig(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		CONFIG_LOCK.RLock()
+		defer CONFIG_LOCK.RUnlock()
+		handler(w, r)
+	}
+}
+
+func (bot *mensabot) stopServer() {
+	if bot.server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := bot.server.Shutdown(ctx); err != nil {
+		slog.Error("Failed to shut down HTTP server", "error", err)
+	}
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !HEALTH.ok() {
+		http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// apiDish is the JSON representation of a dish served by the menu endpoints
+type apiDish struct {
+	Name            string   `json:"name"`
+	Prices          []string `json:"prices"`
+	IsVegetarian    bool     `json:"vegetarian"`
+	IsVegan         bool     `json:"vegan"`
+	ContainsBeef    bool     `json:"beef"`
+	ContainsPork    bool     `json:"pork"`
+	ContainsFish    bool     `json:"fish"`
+	ContainsChicken bool     `json:"chicken"`
+	LactoseFree     bool     `json:"lactose_free"`
+	Additives       []int    `json:"additives"`
+	Calories        int      `json:"calories,omitempty"`
+	Portion         string   `json:"portion,omitempty"`
+}
+
+func newAPIDish(d dish) apiDish {
+	prices := []string{}
+	for _, p := range d.prices {
+		if p != "" {
+			prices = append(prices, p)
+		}
+	}
+	additives := d.additives
+	if additives == nil {
+		additives = []int{}
+	}
+
+	return apiDish{
+		Name:            d.name,
+		Prices:          prices,
+		IsVegetarian:    d.isVegetarian,
+		IsVegan:         d.isVegan,
+		ContainsBeef:    d.containsBeef,
+		ContainsPork:    d.containsPork,
+		ContainsFish:    d.containsFish,
+		ContainsChicken: d.containsChicken,
+		LactoseFree:     d.lactoseFree,
+		Additives:       additives,
+		Calories:        d.calories,
+		Portion:         d.portion,
+	}
+}
+
+// menuHandler serves the plan for the day offset as JSON, the canteen can be picked by name
+// with the "canteen" query parameter
+func menuHandler(offset int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := selectCanteen(r.URL.Query().Get("canteen"))
+		dishes, err := getPlan(c.ID, offset, false)
+		if err != nil {
+			slog.Error("Failed to get canteen plan for menu API", "canteen", c.ID, "error", err)
+			http.Error(w, "failed to get canteen plan", http.StatusBadGateway)
+			return
+		}
+
+		menu := make([]apiDish, 0, len(dishes))
+		for _, d := range dishes {
+			menu = append(menu, newAPIDish(d))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(menu); err != nil {
+			slog.Error("Failed to write menu response", "error", err)
+		}
+	}
+}
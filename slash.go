@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// handleSlashCommand answers Mattermost slash commands like "/mensa morgen vegan" with the
+// matching plan. Mattermost sends the command as form data signed with the integration token
+func handleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(CONFIG.SlashCommandToken)) != 1 {
+		slog.Warn("Rejected slash command with invalid token", "remote_addr", r.RemoteAddr)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	text, userID := r.PostFormValue("text"), r.PostFormValue("user_id")
+	slog.Info("Handling slash command", "text", text, "channel_id", r.PostFormValue("channel_id"), "user_id", userID)
+	METRICS_COMMANDS.inc("slash")
+
+	resp := &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         slashReply(text, userID, r.PostFormValue("channel_id")),
+	}
+	if CONFIG.SlashCommandInChannel {
+		resp.ResponseType = model.COMMAND_RESPONSE_TYPE_IN_CHANNEL
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("Failed to write slash command response", "error", err)
+	}
+}
+
+// slashReply renders the plan requested by the slash command text, today's plan by default
+func slashReply(text string, userID string, channelID string) string {
+	if REG_EXP_HELP.MatchString(text) {
+		return tr("help")
+	}
+
+	c := selectCanteen(text)
+	offset, day, header, when := 0, tr("day.today"), tr("plan.today", c.suffix()), tr("when.today")
+	if REG_EXP_TOMORROW.MatchString(text) {
+		offset, day, header, when = 1, tr("day.tomorrow"), tr("plan.tomorrow", c.suffix()), tr("when.tomorrow")
+	} else if match := REG_EXP_WEEKDAY.FindStringSubmatch(text); match != nil {
+		weekday := WEEKDAYS[strings.ToLower(match[1])]
+		day, when = weekdayName(weekday), tr("when.weekday", weekdayName(weekday))
+		if weekday == time.Saturday || weekday == time.Sunday {
+			return closedMessage(when)
+		}
+		offset, header = weekdayOffset(weekday), tr("plan.weekday", day, c.suffix())
+		if CONFIG.UseMafiasiMensa && offset > 1 {
+			return tr("plan.mafiasi")
+		}
+	}
+
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(text))
+	if err != nil {
+		slog.Error("Failed to get canteen plan for slash command", "canteen", c.ID, "error", err)
+		return tr("fetch.failed")
+	}
+	if len(dishes) == 0 {
+		return closedMessage(when)
+	}
+
+	dishes = visibleDishes(dishes, channelID, REG_EXP_ALL.MatchString(text))
+	if len(dishes) == 0 {
+		return tr("veggie.nothing")
+	}
+
+	if REG_EXP_VEGAN.MatchString(text) {
+		dishes = filterDishes(dishes, func(d dish) bool { return d.isVegan })
+		if len(dishes) == 0 {
+			return tr("filter.none", day, tr("filter.vegan"))
+		}
+	} else if REG_EXP_VEGETARIAN.MatchString(text) {
+		dishes = filterDishes(dishes, func(d dish) bool { return d.isVegetarian })
+		if len(dishes) == 0 {
+			return tr("filter.none", day, tr("filter.vegetarian"))
+		}
+	}
+	return header + "\n\n" + dishTable(dishes, userID)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// STATISTICS_DAYS bounds the recorded days per canteen, older days are dropped
+const STATISTICS_DAYS = 90
+
+// dayStatistics counts the dishes of a canteen on one day by diet
+type dayStatistics struct {
+	Total      int `json:"total"`
+	Vegetarian int `json:"vegetarian"`
+	Vegan      int `json:"vegan"`
+	Meat       int `json:"meat"`
+}
+
+// statisticsStore records the daily dish counts per canteen, keyed by canteen ID and date
+type statisticsStore struct {
+	sync.Mutex
+	path string
+	days map[string]map[string]dayStatistics
+}
+
+var STATISTICS = statisticsStore{days: make(map[string]map[string]dayStatistics)}
+
+// load restores the statistics from path and persists every following change there
+func (s *statisticsStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	days := make(map[string]map[string]dayStatistics)
+	if err := loadJSON(path, &days); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable statistics file", "error", err)
+		}
+		return
+	}
+	s.days = days
+	slog.Info("Restored statistics", "path", path, "canteens", len(days))
+}
+
+// record counts today's dishes of the canteen, replacing the counts recorded earlier today
+func (s *statisticsStore) record(canteenID string, dishes []dish) {
+	if len(dishes) == 0 {
+		return
+	}
+
+	var stats dayStatistics
+	for _, d := range dishes {
+		stats.Total++
+		switch {
+		case d.isVegan:
+			stats.Vegan++
+			stats.Vegetarian++
+		case d.isVegetarian:
+			stats.Vegetarian++
+		case d.containsBeef || d.containsPork || d.containsFish || d.containsChicken:
+			stats.Meat++
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	days := s.days[canteenID]
+	if days == nil {
+		days = make(map[string]dayStatistics)
+		s.days[canteenID] = days
+	}
+	if days[today] == stats {
+		return
+	}
+	days[today] = stats
+
+	// The dates sort chronologically, so the oldest come first
+	if len(days) > STATISTICS_DAYS {
+		dates := make([]string, 0, len(days))
+		for date := range days {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+		for _, date := range dates[:len(dates)-STATISTICS_DAYS] {
+			delete(days, date)
+		}
+	}
+
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.days); err != nil {
+		slog.Error("Failed to persist statistics", "error", err)
+	}
+}
+
+// month sums up the recorded days of the canteen in the month of t, days without a record are skipped
+func (s *statisticsStore) month(canteenID string, t time.Time) (recorded int, veganDays int, vegetarianDays int, sum dayStatistics) {
+	s.Lock()
+	defer s.Unlock()
+
+	prefix := t.Format("2006-01-")
+	for date, stats := range s.days[canteenID] {
+		if !strings.HasPrefix(date, prefix) {
+			continue
+		}
+		recorded++
+		if stats.Vegan > 0 {
+			veganDays++
+		}
+		if stats.Vegetarian > 0 {
+			vegetarianDays++
+		}
+		sum.Total += stats.Total
+		sum.Vegetarian += stats.Vegetarian
+		sum.Vegan += stats.Vegan
+		sum.Meat += stats.Meat
+	}
+	return
+}
+
+// writeStatistics posts how many days of this month had vegan and vegetarian dishes and the
+// share of those dishes
+func (bot *mensabot) writeStatistics(post *model.Post) {
+	c := selectCanteen(post.Message)
+	recorded, veganDays, vegetarianDays, sum := STATISTICS.month(c.ID, time.Now())
+	if recorded == 0 || sum.Total == 0 {
+		bot.sendMessage(tr("statistics.none"), post.ChannelId, post.Id)
+		return
+	}
+
+	percent := func(n int, of int) int { return n * 100 / of }
+	bot.sendMessage(tr("statistics.month", c.suffix(), recorded,
+		veganDays, percent(veganDays, recorded),
+		vegetarianDays, percent(vegetarianDays, recorded),
+		percent(sum.Vegan, sum.Total), percent(sum.Vegetarian, sum.Total), percent(sum.Meat, sum.Total),
+	), post.ChannelId, post.Id)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// subscriberStore holds the IDs of the users receiving the scheduled plan as direct message
+type subscriberStore struct {
+	sync.Mutex
+	path    string
+	userIDs []string
+}
+
+var SUBSCRIBERS = subscriberStore{}
+
+// load restores the subscribers from path and persists every following change there
+func (s *subscriberStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	var userIDs []string
+	if err := loadJSON(path, &userIDs); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable subscriptions file", "error", err)
+		}
+		return
+	}
+	s.userIDs = userIDs
+	slog.Info("Restored subscriptions", "path", path, "users", len(userIDs))
+}
+
+// save must be called with the lock held
+func (s *subscriberStore) save() {
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.userIDs); err != nil {
+		slog.Error("Failed to persist subscriptions", "error", err)
+	}
+}
+
+// add subscribes the user and reports whether they weren't subscribed before
+func (s *subscriberStore) add(userID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, id := range s.userIDs {
+		if id == userID {
+			return false
+		}
+	}
+	s.userIDs = append(s.userIDs, userID)
+	s.save()
+	return true
+}
+
+// remove unsubscribes the user and reports whether they were subscribed
+func (s *subscriberStore) remove(userID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, id := range s.userIDs {
+		if id == userID {
+			s.userIDs = append(s.userIDs[:i:i], s.userIDs[i+1:]...)
+			s.save()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscriberStore) list() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string(nil), s.userIDs...)
+}
+
+func (bot *mensabot) handleSubscription(post *model.Post) {
+	if bot.schedule == nil {
+		bot.sendMessage(tr("subscription.unavailable"), post.ChannelId, post.Id)
+		return
+	}
+
+	switch strings.ToLower(REG_EXP_SUBSCRIPTION.FindStringSubmatch(post.Message)[1]) {
+	case "an", "on":
+		if SUBSCRIBERS.add(post.UserId) {
+			bot.sendMessage(tr("subscription.added", CONFIG.ScheduleTime), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("subscription.exists"), post.ChannelId, post.Id)
+		}
+	default:
+		if SUBSCRIBERS.remove(post.UserId) {
+			bot.sendMessage(tr("subscription.removed"), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("subscription.missing"), post.ChannelId, post.Id)
+		}
+	}
+}
+
+// sendSubscriptions sends today's dishes to every subscriber as direct message, marking their favorites
+func (bot *mensabot) sendSubscriptions(dishes []dish, header string) {
+	for _, userID := range SUBSCRIBERS.list() {
+		var channel *model.Channel
+		resp := withTimeout("CreateDirectChannel", func() (resp *model.Response) {
+			channel, resp = bot.client.CreateDirectChannel(bot.user.Id, userID)
+			return
+		})
+		if resp.Error != nil {
+			// The user may have been deactivated, keep them subscribed in case they return
+			logAppError("Failed to open direct channel to subscriber", resp.Error, "user_id", userID)
+			continue
+		}
+		bot.writeDishes(dishes, header, userID, channel.Id, "")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// veggieChannelStore holds the IDs of the channels which only get shown dishes without meat or fish
+type veggieChannelStore struct {
+	sync.Mutex
+	path       string
+	channelIDs []string
+}
+
+var VEGGIE_CHANNELS = veggieChannelStore{}
+
+// load restores the channels from path and persists every following change there
+func (s *veggieChannelStore) load(path string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.path = path
+	if path == "" {
+		return
+	}
+
+	var channelIDs []string
+	if err := loadJSON(path, &channelIDs); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Ignoring unreadable veggie channel file", "error", err)
+		}
+		return
+	}
+	s.channelIDs = channelIDs
+	slog.Info("Restored veggie channels", "path", path, "channels", len(channelIDs))
+}
+
+// save must be called with the lock held
+func (s *veggieChannelStore) save() {
+	if s.path == "" {
+		return
+	}
+	if err := saveJSON(s.path, s.channelIDs); err != nil {
+		slog.Error("Failed to persist veggie channels", "error", err)
+	}
+}
+
+// add turns the filter on for the channel and reports whether it was off before
+func (s *veggieChannelStore) add(channelID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, id := range s.channelIDs {
+		if id == channelID {
+			return false
+		}
+	}
+	s.channelIDs = append(s.channelIDs, channelID)
+	s.save()
+	return true
+}
+
+// remove turns the filter off for the channel and reports whether it was on
+func (s *veggieChannelStore) remove(channelID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, id := range s.channelIDs {
+		if id == channelID {
+			s.channelIDs = append(s.channelIDs[:i:i], s.channelIDs[i+1:]...)
+			s.save()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *veggieChannelStore) enabled(channelID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, id := range s.channelIDs {
+		if id == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// isMeatFree reports whether the dish contains neither beef, pork, chicken nor fish
+func (d dish) isMeatFree() bool {
+	return !d.containsBeef && !d.containsPork && !d.containsChicken && !d.containsFish
+}
+
+// visibleDishes drops the dishes with meat or fish if the channel only shows vegetarian dishes,
+// unless showAll is set
+func visibleDishes(dishes []dish, channelID string, showAll bool) []dish {
+	if showAll || !VEGGIE_CHANNELS.enabled(channelID) {
+		return dishes
+	}
+	return filterDishes(dishes, dish.isMeatFree)
+}
+
+// writeVisibleDishes posts the dishes like writeDishes, leaving out the ones hidden in the channel
+func (bot *mensabot) writeVisibleDishes(dishes []dish, prefix string, userID string, channelID string, replyToID string, showAll bool) {
+	visible := visibleDishes(dishes, channelID, showAll)
+	if len(visible) == 0 {
+		bot.sendMessage(tr("veggie.nothing"), channelID, replyToID)
+		return
+	}
+	bot.writeDishes(visible, prefix, userID, channelID, replyToID)
+}
+
+// replyDishes answers the post with the dishes, adding 'alle' to the command shows the dishes
+// hidden in the channel
+func (bot *mensabot) replyDishes(dishes []dish, prefix string, post *model.Post) {
+	bot.writeVisibleDishes(dishes, prefix, post.UserId, post.ChannelId, post.Id, REG_EXP_ALL.MatchString(post.Message))
+}
+
+func (bot *mensabot) handleVeggieOnly(post *model.Post) {
+	switch strings.ToLower(REG_EXP_VEGGIE_ONLY.FindStringSubmatch(post.Message)[1]) {
+	case "an", "on":
+		if VEGGIE_CHANNELS.add(post.ChannelId) {
+			bot.sendMessage(tr("veggie.added"), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("veggie.exists"), post.ChannelId, post.Id)
+		}
+	default:
+		if VEGGIE_CHANNELS.remove(post.ChannelId) {
+			bot.sendMessage(tr("veggie.removed"), post.ChannelId, post.Id)
+		} else {
+			bot.sendMessage(tr("veggie.missing"), post.ChannelId, post.Id)
+		}
+	}
+}
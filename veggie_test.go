@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVeggieChannelReplies(t *testing.T) {
+	VEGGIE_CHANNELS.add(DRY_RUN_CHANNEL_ID)
+	defer VEGGIE_CHANNELS.remove(DRY_RUN_CHANNEL_ID)
+
+	tests := []struct {
+		msg     string
+		showAll bool
+		hidden  []string
+	}{
+		{"heute", false, nil},
+		{"heute alle", true, nil},
+		{"morgen", false, nil},
+		{"woche", false, nil},
+		{"suche spaghetti", false, nil},
+		{"csv", false, nil},
+		{"csv alle", true, nil},
+		{"preise", false, nil},
+		{"diagramm", false, nil},
+		{"unter 5", false, nil},
+		{"hauptgerichte", false, nil},
+		{"lieblings woche", false, nil},
+		// Replies without dish names mustn't count the hidden dishes either
+		{"übersicht", false, []string{tr("summary.beef.one"), tr("summary.fish.one")}},
+		{"durchschnitt", false, []string{"3,40 €"}},
+	}
+	for _, tt := range tests {
+		out := useDryRun(t, "plan.html")
+		CONFIG.Favorites = []string{"Spaghetti", "Seelachs", "Curry"}
+		post := newDryRunPost(tt.msg)
+		newDryRunBot(&CONFIG).runCommand(post)
+
+		reply := out.String()
+		if got := strings.Contains(reply, "Bolognese"); got != tt.showAll {
+			t.Errorf("%q: shows meat dishes: %v, want %v\n%s", tt.msg, got, tt.showAll, reply)
+		}
+		if !tt.showAll && strings.Contains(reply, "Seelachs") {
+			t.Errorf("%q: shows fish dishes\n%s", tt.msg, reply)
+		}
+		for _, hidden := range tt.hidden {
+			if strings.Contains(reply, hidden) {
+				t.Errorf("%q: reply contains %q\n%s", tt.msg, hidden, reply)
+			}
+		}
+	}
+}
+
+func TestVeggieChannelSlashReply(t *testing.T) {
+	VEGGIE_CHANNELS.add(DRY_RUN_CHANNEL_ID)
+	defer VEGGIE_CHANNELS.remove(DRY_RUN_CHANNEL_ID)
+	useDryRun(t, "plan.html")
+
+	if reply := slashReply("heute", DRY_RUN_USER_ID, DRY_RUN_CHANNEL_ID); strings.Contains(reply, "Bolognese") || !strings.Contains(reply, "Pommes frites") {
+		t.Errorf("slash command in veggie channel replied:\n%s", reply)
+	}
+	if reply := slashReply("heute", DRY_RUN_USER_ID, "town-square"); !strings.Contains(reply, "Bolognese") {
+		t.Errorf("slash command in other channel replied:\n%s", reply)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// NEXT_DAY_LIMIT is the number of days looked ahead when searching the next day with a matching dish
+const NEXT_DAY_LIMIT = 7
+
+// DATE_LIMIT is the number of days ahead the Studierendenwerk site has plans for
+const DATE_LIMIT = 14
+
+// dayPlan is the result of fetching the plan of one weekday
+type dayPlan struct {
+	day    time.Weekday
+	offset int
+	dishes []dish
+	err    error
+}
+
+// getWeekPlans concurrently fetches the plans of the next occurrence of each weekday from
+// Monday to Friday, ordered by date. Mafiasi only knows today's and tomorrow's plans, so the
+// other days are left out then
+func getWeekPlans(canteenID string) []dayPlan {
+	plans := make([]dayPlan, 0, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		offset := weekdayOffset(day)
+		if CONFIG.UseMafiasiMensa && offset > 1 {
+			continue
+		}
+		plans = append(plans, dayPlan{day: day, offset: offset})
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].offset < plans[j].offset })
+
+	var wg sync.WaitGroup
+	for i := range plans {
+		wg.Add(1)
+		go func(p *dayPlan) {
+			defer wg.Done()
+			p.dishes, p.err = getPlan(canteenID, p.offset, false)
+		}(&plans[i])
+	}
+	wg.Wait()
+
+	return plans
+}
+
+func (bot *mensabot) writeWeek(post *model.Post) {
+	c := selectCanteen(post.Message)
+
+	plans := getWeekPlans(c.ID)
+	if len(plans) == 0 {
+		bot.sendMessage(tr("plan.mafiasi"), post.ChannelId, post.Id)
+		return
+	}
+
+	var buf bytes.Buffer
+	var failed []string
+	buf.WriteString(tr("plan.week", c.suffix()) + "\n\n")
+	for _, p := range plans {
+		name := weekdayName(p.day)
+		if p.err != nil {
+			slog.Error("Failed to get canteen plan for weekly overview", "day", name, "error", p.err)
+			failed = append(failed, name)
+			continue
+		}
+
+		buf.WriteString("#### " + name + "\n")
+		if len(p.dishes) == 0 {
+			buf.WriteString(closedMessage(tr("when.weekday", name)) + "\n\n")
+			continue
+		}
+		dishes := visibleDishes(p.dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message))
+		if len(dishes) == 0 {
+			buf.WriteString(tr("veggie.nothing") + "\n\n")
+			continue
+		}
+		buf.WriteString(dishTable(dishes, post.UserId) + "\n")
+	}
+
+	if len(failed) == len(plans) {
+		bot.sendMessage(tr("fetch.failed"), post.ChannelId, post.Id)
+		return
+	}
+	if len(failed) > 0 {
+		buf.WriteString(tr("week.failed", joinWords(failed)) + "\n")
+	}
+	if len(plans) < 5 {
+		buf.WriteString("_" + tr("plan.mafiasi") + "_\n")
+	}
+	bot.sendMessage(buf.String(), post.ChannelId, post.Id)
+}
+
+// writeVeganWeek posts the vegan dishes of each day from Monday to Friday in one message
+func (bot *mensabot) writeVeganWeek(post *model.Post) {
+	c := selectCanteen(post.Message)
+
+	plans := getWeekPlans(c.ID)
+	if len(plans) == 0 {
+		bot.sendMessage(tr("plan.mafiasi"), post.ChannelId, post.Id)
+		return
+	}
+
+	var buf bytes.Buffer
+	var failed []string
+	buf.WriteString(tr("vegan_week.header", c.suffix()) + "\n\n")
+	for _, p := range plans {
+		name := weekdayName(p.day)
+		if p.err != nil {
+			slog.Error("Failed to get canteen plan for vegan weekly overview", "day", name, "error", p.err)
+			failed = append(failed, name)
+			continue
+		}
+
+		buf.WriteString("#### " + name + "\n")
+		if len(p.dishes) == 0 {
+			buf.WriteString(closedMessage(tr("when.weekday", name)) + "\n\n")
+			continue
+		}
+		vegan := filterDishes(p.dishes, func(d dish) bool { return d.isVegan })
+		if len(vegan) == 0 {
+			buf.WriteString(tr("vegan_week.none") + "\n\n")
+			continue
+		}
+		buf.WriteString(dishTable(vegan, post.UserId) + "\n")
+	}
+
+	if len(failed) == len(plans) {
+		bot.sendMessage(tr("fetch.failed"), post.ChannelId, post.Id)
+		return
+	}
+	if len(failed) > 0 {
+		buf.WriteString(tr("week.failed", joinWords(failed)) + "\n")
+	}
+	if len(plans) < 5 {
+		buf.WriteString("_" + tr("plan.mafiasi") + "_\n")
+	}
+	bot.sendMessage(buf.String(), post.ChannelId, post.Id)
+}
+
+// writeFavoriteWeek lists on which days from Monday to Friday the user's favorites are served
+func (bot *mensabot) writeFavoriteWeek(post *model.Post) {
+	c := selectCanteen(post.Message)
+
+	plans := getWeekPlans(c.ID)
+	if len(plans) == 0 {
+		bot.sendMessage(tr("plan.mafiasi"), post.ChannelId, post.Id)
+		return
+	}
+
+	var lines []string
+	var failed []string
+	for _, p := range plans {
+		name := weekdayName(p.day)
+		if p.err != nil {
+			slog.Error("Failed to get canteen plan for favorite weekly overview", "day", name, "error", p.err)
+			failed = append(failed, name)
+			continue
+		}
+
+		var favorites []string
+		for _, d := range visibleDishes(p.dishes, post.ChannelId, REG_EXP_ALL.MatchString(post.Message)) {
+			if d.isFavorite(post.UserId) {
+				favorites = append(favorites, d.displayName())
+			}
+		}
+		if len(favorites) > 0 {
+			lines = append(lines, "- **"+name+":** "+strings.Join(favorites, ", "))
+		}
+	}
+
+	if len(failed) == len(plans) {
+		bot.sendMessage(tr("fetch.failed"), post.ChannelId, post.Id)
+		return
+	}
+
+	msg := tr("favorite_week.none")
+	if len(lines) > 0 {
+		msg = tr("favorite_week.header", c.suffix()) + "\n" + strings.Join(lines, "\n")
+	}
+	if len(failed) > 0 {
+		msg += "\n\n" + tr("week.failed", joinWords(failed))
+	}
+	if len(plans) < 5 {
+		msg += "\n\n_" + tr("plan.mafiasi") + "_"
+	}
+	bot.sendMessage(msg, post.ChannelId, post.Id)
+}
+
+// writeNextDay fetches the plans day by day and posts the dishes of the first day with a dish
+// matching the requested diet, e.g. for "nächster veganer Tag"
+func (bot *mensabot) writeNextDay(post *model.Post) {
+	pred, label := func(d dish) bool { return d.isVegetarian }, tr("filter.vegetarian")
+	if strings.EqualFold(REG_EXP_NEXT_DAY.FindStringSubmatch(post.Message)[1], "vegan") {
+		pred, label = func(d dish) bool { return d.isVegan }, tr("filter.vegan")
+	}
+
+	c := selectCanteen(post.Message)
+	today := time.Now()
+	for offset := 0; offset < NEXT_DAY_LIMIT; offset++ {
+		// Mafiasi only knows today's and tomorrow's plans
+		if CONFIG.UseMafiasiMensa && offset > 1 {
+			break
+		}
+		day := today.AddDate(0, 0, offset).Weekday()
+		if day == time.Saturday || day == time.Sunday {
+			continue
+		}
+
+		dishes, err := getPlan(c.ID, offset, false)
+		if err != nil {
+			bot.writeFetchError(err, post.ChannelId, post.Id)
+			return
+		}
+		if matches := filterDishes(dishes, pred); len(matches) > 0 {
+			when := tr("when.weekday", weekdayName(day))
+			switch offset {
+			case 0:
+				when = tr("when.today")
+			case 1:
+				when = tr("when.tomorrow")
+			}
+			bot.replyDishes(matches, tr("next.found", when, label, c.suffix()), post)
+			return
+		}
+	}
+
+	bot.sendMessage(tr("next.none", label), post.ChannelId, post.Id)
+}
+
+// parseDate parses the day and month and the optional year matched by REG_EXP_DATE into a date
+// in location. Without a year the next occurrence from today on is meant, e.g. "02.01." in December
+func parseDate(match []string, today time.Time, location *time.Location) (time.Time, bool) {
+	day, _ := strconv.Atoi(match[1])
+	month, _ := strconv.Atoi(match[2])
+	year := today.Year()
+	if match[3] != "" {
+		year, _ = strconv.Atoi(match[3])
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+	// time.Date normalizes invalid dates like 31.02. instead of rejecting them
+	if date.Day() != day || int(date.Month()) != month {
+		return time.Time{}, false
+	}
+	if match[3] == "" && date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
+
+// writeDate posts the plan of a date like "24.12." or "24.12.2024" within the next DATE_LIMIT days
+func (bot *mensabot) writeDate(post *model.Post) {
+	location, err := time.LoadLocation(SCHEDULE_TIMEZONE)
+	if err != nil {
+		location = time.Local
+	}
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	match := REG_EXP_DATE.FindStringSubmatch(post.Message)
+	date, ok := parseDate(match, today, location)
+	if !ok {
+		bot.sendMessage(tr("date.invalid", strings.TrimSpace(match[0])), post.ChannelId, post.Id)
+		return
+	}
+
+	// Rounding keeps the offset right across daylight saving time changes
+	offset := int(date.Sub(today).Round(24*time.Hour) / (24 * time.Hour))
+	if offset < 0 || offset > DATE_LIMIT {
+		bot.sendMessage(tr("date.range", DATE_LIMIT), post.ChannelId, post.Id)
+		return
+	}
+	if CONFIG.UseMafiasiMensa && offset > 1 {
+		bot.sendMessage(tr("plan.mafiasi"), post.ChannelId, post.Id)
+		return
+	}
+
+	when := tr("when.date", weekdayName(date.Weekday()), date.Format("02.01.2006"))
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		bot.sendMessage(closedMessage(when), post.ChannelId, post.Id)
+		return
+	}
+
+	c := selectCanteen(post.Message)
+	dishes, err := getPlan(c.ID, offset, REG_EXP_REFRESH.MatchString(post.Message))
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+	if len(dishes) == 0 {
+		bot.sendMessage(closedMessage(when), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(dishes, tr("plan.date", weekdayName(date.Weekday()), date.Format("02.01.2006"), c.suffix()), post)
+}
+
+// writeNewTomorrow posts the dishes of tomorrow's plan which aren't on today's
+func (bot *mensabot) writeNewTomorrow(post *model.Post) {
+	c := selectCanteen(post.Message)
+	refresh := REG_EXP_REFRESH.MatchString(post.Message)
+	today, err := getPlan(c.ID, 0, refresh)
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+	tomorrow, err := getPlan(c.ID, 1, refresh)
+	if err != nil {
+		bot.writeFetchError(err, post.ChannelId, post.Id)
+		return
+	}
+
+	if len(tomorrow) == 0 {
+		bot.sendMessage(closedMessage(tr("when.tomorrow")), post.ChannelId, post.Id)
+		return
+	}
+
+	served := make(map[string]bool, len(today))
+	for _, d := range today {
+		served[normalizeDishName(d.name)] = true
+	}
+	fresh := filterDishes(tomorrow, func(d dish) bool { return !served[normalizeDishName(d.name)] })
+	if len(fresh) == 0 {
+		bot.sendMessage(tr("new.none"), post.ChannelId, post.Id)
+		return
+	}
+	bot.replyDishes(fresh, tr("new.header", c.suffix()), post)
+}
+
+// joinWords joins words as an enumeration, e.g. "Montag, Dienstag und Freitag"
+func joinWords(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	var buf bytes.Buffer
+	for i, w := range words[:len(words)-1] {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(w)
+	}
+	return buf.String() + " " + tr("and") + " " + words[len(words)-1]
+}
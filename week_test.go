@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeekdayOffset(t *testing.T) {
+	today := time.Now().Weekday()
+	if got := weekdayOffset(today); got != 0 {
+		t.Errorf("weekdayOffset(today) = %d, want 0", got)
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		offset := weekdayOffset(day)
+		if offset < 0 || offset > 6 {
+			t.Errorf("weekdayOffset(%s) = %d, want 0 to 6", day, offset)
+		}
+		if got := time.Weekday((int(today) + offset) % 7); got != day {
+			t.Errorf("weekdayOffset(%s) = %d leads to %s", day, offset, got)
+		}
+	}
+}
+
+func TestGetWeekPlansMafiasi(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.UseMafiasiMensa = true
+	useConfig(t, cfg)
+
+	// Serve today's and tomorrow's plans from the cache, any other request would go out
+	for _, url := range []string{CANTEEN_URL_MAFIASI_TODAY, CANTEEN_URL_MAFIASI_TOMORROW} {
+		key := strings.Replace(url, "{0}", "test-canteen", 1)
+		PLAN_CACHE.put(key, []dish{{name: "Pizza"}})
+		t.Cleanup(func() { PLAN_CACHE.invalidate(key) })
+	}
+
+	want := 0
+	for offset := 0; offset <= 1; offset++ {
+		if !isWeekend(time.Now().AddDate(0, 0, offset)) {
+			want++
+		}
+	}
+
+	plans := getWeekPlans("test-canteen")
+	if len(plans) != want {
+		t.Errorf("got %d plans, want %d", len(plans), want)
+	}
+	for _, p := range plans {
+		if p.offset > 1 || p.err != nil || len(p.dishes) != 1 {
+			t.Errorf("%s: got offset %d, %d dishes and error %v", p.day, p.offset, len(p.dishes), p.err)
+		}
+	}
+}
+
+func TestJoinWords(t *testing.T) {
+	useConfig(t, defaultConfig())
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"Montag"}, "Montag"},
+		{[]string{"Montag", "Dienstag"}, "Montag und Dienstag"},
+		{[]string{"Montag", "Dienstag", "Freitag"}, "Montag, Dienstag und Freitag"},
+	}
+	for _, tt := range tests {
+		if got := joinWords(tt.words); got != tt.want {
+			t.Errorf("joinWords(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	location := time.UTC
+	today := time.Date(2026, time.December, 20, 0, 0, 0, 0, location)
+
+	tests := []struct {
+		msg    string
+		want   string
+		wantOK bool
+	}{
+		{"24.12.", "2026-12-24", true},
+		{"am 1.12. bitte", "2027-12-01", true},
+		{"20.12.", "2026-12-20", true},
+		{"2.1.", "2027-01-02", true},
+		{"24.12.2026", "2026-12-24", true},
+		{"1.12.2026", "2026-12-01", true},
+		{"29.2.", "", false},
+		{"29.02.2028", "2028-02-29", true},
+		{"31.04.", "", false},
+		{"12.13.", "", false},
+		{"0.12.", "", false},
+	}
+	for _, tt := range tests {
+		match := REG_EXP_DATE.FindStringSubmatch(tt.msg)
+		if match == nil {
+			t.Errorf("%q didn't match REG_EXP_DATE", tt.msg)
+			continue
+		}
+		date, ok := parseDate(match, today, location)
+		if ok != tt.wantOK || (ok && date.Format("2006-01-02") != tt.want) {
+			t.Errorf("parseDate(%q) = %s, %v, want %s, %v", tt.msg, date.Format("2006-01-02"), ok, tt.want, tt.wantOK)
+		}
+	}
+}